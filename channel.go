@@ -0,0 +1,40 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// channels from the most to the least stable, only tukui has nightly builds
+var channels = []string{"stable", "beta", "alpha", "nightly"}
+
+// normalizeChannel lowercases c, "" means stable.
+func normalizeChannel(c string) (string, error) {
+	c = strings.ToLower(strings.TrimSpace(c))
+	if c == "" {
+		return "stable", nil
+	}
+	if !containsFold(channels, c) {
+		return "", errors.Errorf("unknown channel %q, expected one of %v", c, channels)
+	}
+	return c, nil
+}
+
+// channelsUpTo lists the channels acceptable when following c, e.g. beta
+// accepts stable and beta builds.
+func channelsUpTo(c string) []string {
+	c, _ = normalizeChannel(c)
+	for i, name := range channels {
+		if name == c {
+			return channels[:i+1]
+		}
+	}
+	return channels[:1]
+}
+
+// channel is the normalized Channel, validated when the config was loaded.
+func (e *elvui) channel() string {
+	c, _ := normalizeChannel(e.Channel)
+	return c
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// group lists the folders making up the addon: its own and the Companions
+// released along with it, e.g. ElvUI and ElvUI_Options.
+func (e *elvui) group() []string {
+	return append([]string{e.folderName()}, e.Companions...)
+}
+
+// checkGroup refuses an archive that lacks a folder of the group, installing
+// it would leave a companion from another release.
+func (e *elvui) checkGroup(archived []string) error {
+	if len(e.Companions) == 0 {
+		return nil
+	}
+	if missing := difference(e.group(), archived); len(missing) > 0 {
+		return errors.Errorf("archive from %s lacks %v, refusing a partial update of %s", e.downloadURL, missing, e.Name)
+	}
+	return nil
+}
+
+// checkCompanionVersions warns about companions whose TOC reports another
+// version than the addon's own one.
+func (e *elvui) checkCompanionVersions(want version) {
+	for _, dir := range e.Companions {
+		folder := findFold(e.addon, dir)
+		toc := findFold(folder, dir+flavorTOCSuffixes[e.flavor()]+".toc")
+		if _, err := os.Stat(toc); err != nil {
+			toc = findFold(folder, dir+".toc")
+		}
+		f, err := os.Open(toc)
+		if err != nil {
+			e.verbosef("%s: companion %s has no TOC to check, %v\n", e.Name, dir, err)
+			continue
+		}
+		v, err := e.readTOCVersion(f, toc)
+		f.Close()
+		if err != nil {
+			e.verbosef("%s: companion %s, %v\n", e.Name, dir, err)
+		} else if v.compare(want) != 0 {
+			e.warnf("%s: companion %s reports %s, expected %s\n", e.Name, dir, v, want)
+		}
+	}
+}
+
+// asidePrefix starts the name of the folders swap moves the old files to.
+const asidePrefix = ".elvuiUpdater-old-"
+
+// sweepAside removes the folders of old files a crashed run left in AddOns.
+// Those less than an hour old are kept, another run may be swapping yet.
+func (c *configuration) sweepAside() {
+	entries, err := ioutil.ReadDir(c.addon)
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() || !strings.HasPrefix(entry.Name(), asidePrefix) || time.Since(entry.ModTime()) < time.Hour {
+			continue
+		}
+		dir := filepath.Join(c.addon, entry.Name())
+		verbosef("removing %s, left behind by an interrupted update\n", dir)
+		if err := os.RemoveAll(dir); err != nil {
+			warnf("cannot remove %s, %v\n", dir, err)
+		}
+	}
+}
+
+// swap replaces the old folders with what staging holds as one step: the old
+// ones are renamed aside within AddOns first and put back should a move
+// fail, so the addon and its companions never end up from different
+// releases.
+func (e *elvui) swap(staging string, old []string) error {
+	staged, err := ioutil.ReadDir(staging)
+	if err != nil {
+		return errors.Wrapf(err, "cannot read staging directory %s", staging)
+	}
+	targets := append([]string(nil), old...)
+	for _, entry := range staged {
+		targets = append(targets, filepath.Join(e.addon, entry.Name()))
+	}
+
+	aside, err := ioutil.TempDir(e.addon, asidePrefix)
+	if err != nil {
+		return errors.Wrap(err, "cannot create a folder for the old files")
+	}
+	defer e.removeAll(aside)
+	type renamed struct{ from, to string }
+	var moved []renamed
+	var installed []string
+	rollback := func() {
+		for _, dst := range installed {
+			e.removeAll(dst)
+		}
+		for i := len(moved) - 1; i >= 0; i-- {
+			if err := os.Rename(moved[i].to, moved[i].from); err != nil {
+				e.errorf("%s: cannot restore %s, %v\n", e.Name, moved[i].from, err)
+			}
+		}
+	}
+
+	for i, target := range targets {
+		if _, err := os.Lstat(target); os.IsNotExist(err) {
+			continue
+		}
+		to := filepath.Join(aside, filepath.Base(target)+"-"+strconv.Itoa(i))
+		if err := os.Rename(target, to); err != nil {
+			rollback()
+			return errors.Wrapf(err, "cannot move %s aside", target)
+		}
+		moved = append(moved, renamed{target, to})
+	}
+	for _, entry := range staged {
+		src, dst := filepath.Join(staging, entry.Name()), filepath.Join(e.addon, entry.Name())
+		if err := move(src, dst); err != nil {
+			installed = append(installed, dst)
+			rollback()
+			return err
+		}
+		installed = append(installed, dst)
+	}
+	return nil
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// settingSources tells where each setting came from: the config file, an
+// environment variable, the keychain or a flag. Addon settings are keyed
+// "Addons.<name>.<field>", missing keys are defaults.
+type settingSources map[string]string
+
+// recordSources notes every setting the config file sets, call it once the
+// environment is expanded so expanded values can be told apart.
+func (c *configuration) recordSources(raw []byte, source string) {
+	c.sources = settingSources{}
+	var top map[string]json.RawMessage
+	if json.Unmarshal(raw, &top) != nil {
+		return
+	}
+	addons := c.addons()
+	cv := reflect.ValueOf(c).Elem()
+	var defaults map[string]json.RawMessage
+	for key, value := range top {
+		field, ok := fieldFold(cv.Type(), key)
+		switch {
+		case !ok:
+		case field == "Defaults":
+			json.Unmarshal(value, &defaults)
+		case field == "Addons":
+			var entries []map[string]json.RawMessage
+			json.Unmarshal(value, &entries)
+			for i, entry := range entries {
+				if i < len(c.Addons) {
+					c.recordAddon(addons[i].Name, reflect.ValueOf(c.Addons[i]), entry, source)
+				}
+			}
+		case isAddonField(field) && len(c.Addons) == 0:
+			c.recordAddon(addons[0].Name, reflect.ValueOf(c.addonConfig), map[string]json.RawMessage{key: value}, source)
+		default:
+			c.sources[field] = sourceOf(value, cv.FieldByName(field), source)
+		}
+	}
+	for key, value := range defaults {
+		field, ok := fieldFold(reflect.TypeOf(addonConfig{}), key)
+		if !ok {
+			continue
+		}
+		for _, a := range addons {
+			if k := "Addons." + a.Name + "." + field; c.sources[k] == "" {
+				c.sources[k] = sourceOf(value, reflect.ValueOf(c.Defaults).FieldByName(field), source) + ", Defaults"
+			}
+		}
+	}
+}
+
+func (c *configuration) recordAddon(name string, v reflect.Value, entry map[string]json.RawMessage, source string) {
+	for key, value := range entry {
+		if field, ok := fieldFold(v.Type(), key); ok {
+			c.sources["Addons."+name+"."+field] = sourceOf(value, v.FieldByName(field), source)
+		}
+	}
+}
+
+// setSource records source for the setting key, when it is tracked at all.
+func (c *configuration) setSource(key, source string) {
+	if c.sources != nil {
+		c.sources[key] = source
+	}
+}
+
+// fieldFold finds the exported field named key, ignoring case the way
+// encoding/json does.
+func fieldFold(t reflect.Type, key string) (string, bool) {
+	if f, ok := t.FieldByNameFunc(func(name string) bool { return strings.EqualFold(name, key) }); ok && f.PkgPath == "" {
+		return f.Name, true
+	}
+	return "", false
+}
+
+func isAddonField(name string) bool {
+	_, ok := reflect.TypeOf(addonConfig{}).FieldByName(name)
+	return ok
+}
+
+// sourceOf flags values that differ from the file, $VAR expansion did it.
+func sourceOf(raw json.RawMessage, v reflect.Value, source string) string {
+	decoded := reflect.New(v.Type())
+	if json.Unmarshal(raw, decoded.Interface()) == nil && !reflect.DeepEqual(decoded.Elem().Interface(), v.Interface()) {
+		return source + ", env expanded"
+	}
+	return source
+}
+
+// printSources writes the source of every printed setting in a table, top
+// level ones first, then each addon's non-empty ones.
+func (c *configuration) printSources(out map[string]interface{}, addons []addonConfig) {
+	w := tabwriter.NewWriter(os.Stderr, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "SETTING\tSOURCE")
+	keys := make([]string, 0, len(out))
+	for key := range out {
+		if key != "Addons" {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		fmt.Fprintf(w, "%s\t%s\n", key, c.source(key))
+	}
+	for _, a := range addons {
+		v := reflect.ValueOf(a)
+		for i := 0; i < v.NumField(); i++ {
+			key := "Addons." + a.Name + "." + v.Type().Field(i).Name
+			if c.sources[key] != "" || !v.Field(i).IsZero() {
+				fmt.Fprintf(w, "%s\t%s\n", key, c.source(key))
+			}
+		}
+	}
+	w.Flush()
+}
+
+func (c *configuration) source(key string) string {
+	if s := c.sources[key]; s != "" {
+		return s
+	}
+	return "default"
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// configTest goes over what loadConfig can't catch without a run: provider
+// settings, credentials and the installed TOC files. It makes no request and
+// changes nothing, the problems found are returned.
+func (c *configuration) configTest(addons []addonConfig) []string {
+	var problems []string
+	if _, err := os.Stat(c.addon); os.IsNotExist(err) {
+		if root := filepath.Dir(filepath.Dir(c.addon)); !isDir(root) {
+			problems = append(problems, "WoW install path is missing: "+root+", check InstallPath")
+		} else {
+			infof("%s doesn't exist yet, it will be created\n", c.addon)
+		}
+	} else if err := checkWritable(c.addon); err != nil {
+		problems = append(problems, "AddOns is not usable: "+err.Error())
+	} else {
+		infof("Installing into %s\n", c.addon)
+	}
+
+	for _, a := range addons {
+		e := &elvui{configuration: c, addonConfig: a, log: log.New(os.Stderr, "", log.LstdFlags)}
+		for _, err := range e.checkSettings() {
+			problems = append(problems, a.Name+": "+err.Error())
+		}
+		if !isDir(c.addon) {
+			continue
+		}
+		v, err := e.readLocalVersion()
+		switch {
+		case os.IsNotExist(errors.Cause(err)):
+			infof("%s: not installed yet\n", a.Name)
+		case err != nil:
+			problems = append(problems, a.Name+": "+err.Error())
+		default:
+			infof("%s: %s installed\n", a.Name, v)
+		}
+	}
+	return problems
+}
+
+// checkSettings reports the settings the addon's provider needs but lacks.
+func (e *elvui) checkSettings() []error {
+	var errs []error
+	switch strings.ToLower(e.Provider) {
+	case "", "tukui", "html":
+		if err := checkURL(e.Page); err != nil {
+			errs = append(errs, errors.Wrap(err, "Page"))
+		}
+	case "wowinterface":
+		if e.AddonID == "" {
+			errs = append(errs, errors.New("wowinterface provider needs AddonID"))
+		}
+	case "curseforge":
+		if e.AddonID == "" {
+			errs = append(errs, errors.New("curseforge provider needs AddonID"))
+		}
+		if e.CurseForgeAPIKey == "" {
+			errs = append(errs, errors.New("curseforge provider needs CurseForgeAPIKey"))
+		}
+	case "wago":
+		if e.AddonID == "" {
+			errs = append(errs, errors.New("wago provider needs AddonID"))
+		}
+		if e.WagoAPIKey == "" {
+			errs = append(errs, errors.New("wago provider needs WagoAPIKey"))
+		}
+	case "github":
+		if strings.Count(e.Repo, "/") != 1 {
+			errs = append(errs, errors.Errorf("github provider needs Repo as owner/name, got %q", e.Repo))
+		}
+	case "exec":
+		if e.ResolveCommand == "" {
+			errs = append(errs, errors.New("exec provider needs ResolveCommand"))
+		}
+	}
+	if e.ManifestURL != "" {
+		if err := checkURL(e.ManifestURL); err != nil {
+			errs = append(errs, errors.Wrap(err, "ManifestURL"))
+		}
+	}
+	return errs
+}
+
+// checkURL accepts absolute http and https URLs.
+func checkURL(raw string) error {
+	if raw == "" {
+		return errors.New("missing URL")
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return errors.Wrapf(err, "invalid URL %s", raw)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" || u.Host == "" {
+		return errors.Errorf("%s is not an http or https URL", raw)
+	}
+	return nil
+}
+
+// isDir reports whether path is an existing directory.
+func isDir(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
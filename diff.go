@@ -0,0 +1,106 @@
+package main
+
+import (
+	"archive/zip"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+)
+
+// diff downloads the remote archive and reports which installed files it
+// would add, modify or remove, without touching the install.
+func (e *elvui) diff() error {
+	defer e.emit(progressEvent{kind: progressDone})
+	archive, size, err := e.download()
+	if err != nil {
+		return err
+	}
+	defer os.Remove(archive.Name())
+	defer archive.Close()
+	e.downloaded += size
+
+	zipReader, err := e.openArchive(archive, size)
+	if err != nil {
+		return err
+	}
+
+	var added, modified, removed []string
+	inArchive := map[string]bool{}
+	for _, f := range zipReader.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		name := filepath.FromSlash(f.Name)
+		inArchive[name] = true
+
+		changed, err := fileChanged(filepath.Join(e.addon, name), f)
+		if os.IsNotExist(err) {
+			added = append(added, name)
+		} else if err != nil {
+			return err
+		} else if changed {
+			modified = append(modified, name)
+		}
+	}
+
+	for _, dir := range e.cleanupDirectories(topLevelDirs(zipReader)) {
+		root := filepath.Join(e.addon, dir)
+		err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+			if os.IsNotExist(err) && path == root {
+				return filepath.SkipDir
+			} else if err != nil {
+				return err
+			}
+			rel, err := filepath.Rel(e.addon, path)
+			if err != nil {
+				return err
+			}
+			if !info.IsDir() && !inArchive[rel] {
+				removed = append(removed, rel)
+			}
+			return nil
+		})
+		if err != nil {
+			return errors.Wrapf(err, "cannot walk %s", root)
+		}
+	}
+
+	e.infof("%s %s->%s: %d added, %d modified, %d removed\n", e.Name, e.localVersion, e.remoteVersion, len(added), len(modified), len(removed))
+	for _, name := range added {
+		e.infof("  + %s\n", name)
+	}
+	for _, name := range modified {
+		e.infof("  ~ %s\n", name)
+	}
+	for _, name := range removed {
+		e.infof("  - %s\n", name)
+	}
+
+	return nil
+}
+
+// fileChanged compares a local file with a zip entry by size, then CRC32.
+func fileChanged(path string, f *zip.File) (bool, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return false, err
+	}
+	if uint64(info.Size()) != f.UncompressedSize64 {
+		return true, nil
+	}
+
+	local, err := os.Open(path)
+	if err != nil {
+		return false, errors.Wrapf(err, "cannot open %s", path)
+	}
+	defer local.Close()
+	h := crc32.NewIEEE()
+	if _, err := io.Copy(h, local); err != nil {
+		return false, errors.Wrapf(err, "cannot read %s", path)
+	}
+
+	return h.Sum32() != f.CRC32, nil
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// execProvider hands the resolution to ResolveCommand, run through the
+// system shell with ELVUIUPDATER_ADDON, ELVUIUPDATER_FLAVOR,
+// ELVUIUPDATER_CHANNEL and ELVUIUPDATER_OLD_VERSION. It must print a
+// {"version": ..., "url": ...} object, optionally with an "md5", within
+// RequestTimeout. The download goes through the usual pipeline.
+type execProvider struct{}
+
+// endpoint is empty, there is no API to ping.
+func (execProvider) endpoint(e *elvui) string {
+	return ""
+}
+
+func (execProvider) latest(e *elvui) (release, error) {
+	if e.ResolveCommand == "" {
+		return release{}, errors.New("exec provider needs ResolveCommand")
+	}
+
+	timeout := e.RequestTimeout.or(30 * time.Second)
+	ctx, cancel := context.WithTimeout(e.context(), timeout)
+	defer cancel()
+	cmd := shellCommand(ctx, e.ResolveCommand)
+	cmd.Env = append(os.Environ(),
+		"ELVUIUPDATER_ADDON="+e.Name,
+		"ELVUIUPDATER_FLAVOR="+e.flavor(),
+		"ELVUIUPDATER_CHANNEL="+e.channel(),
+		"ELVUIUPDATER_OLD_VERSION="+e.localVersion.raw,
+	)
+	// files rather than pipes, so a killed shell isn't waited for until its
+	// children close their output too
+	var outputs [2]*os.File
+	for i := range outputs {
+		f, err := ioutil.TempFile(e.TempDir, "elvuiUpdater-resolve-")
+		if err != nil {
+			return release{}, errors.Wrap(err, "cannot create temp file")
+		}
+		defer os.Remove(f.Name())
+		defer f.Close()
+		outputs[i] = f
+	}
+	cmd.Stdout, cmd.Stderr = outputs[0], outputs[1]
+	err := cmd.Run()
+	if msg, _ := ioutil.ReadFile(outputs[1].Name()); len(strings.TrimSpace(string(msg))) > 0 {
+		e.verbosef("%s resolver: %s\n", e.Name, strings.TrimSpace(string(msg)))
+	}
+	if ctx.Err() == context.DeadlineExceeded {
+		return release{}, errors.Errorf("ResolveCommand of %s took longer than %s", e.Name, timeout)
+	}
+	if err != nil {
+		return release{}, errors.Wrapf(err, "ResolveCommand of %s failed", e.Name)
+	}
+	out, err := ioutil.ReadFile(outputs[0].Name())
+	if err != nil {
+		return release{}, errors.WithStack(err)
+	}
+
+	var r struct {
+		Version string `json:"version"`
+		URL     string `json:"url"`
+		MD5     string `json:"md5"`
+	}
+	if err := json.Unmarshal(out, &r); err != nil {
+		return release{}, errors.Wrapf(err, "ResolveCommand of %s printed no JSON object", e.Name)
+	}
+	if r.Version == "" {
+		return release{}, errors.Errorf("ResolveCommand of %s printed no version", e.Name)
+	}
+	if err := checkURL(r.URL); err != nil {
+		return release{}, errors.Wrapf(err, "ResolveCommand of %s printed a bad url", e.Name)
+	}
+
+	return e.pickHighest([]candidate{{version: r.Version, url: r.URL, md5: strings.ToLower(r.MD5)}})
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/pkg/errors"
+)
+
+// fileMode reads octal permission strings such as "0640" from the config.
+type fileMode os.FileMode
+
+func (m *fileMode) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return errors.Errorf("file modes are octal strings like \"0644\", got %s", b)
+	}
+	v, err := strconv.ParseUint(s, 8, 32)
+	if err != nil || v > 0777 {
+		return errors.Errorf("invalid file mode %q, expected an octal string like \"0644\"", s)
+	}
+	*m = fileMode(v)
+	return nil
+}
+
+func (m fileMode) MarshalJSON() ([]byte, error) {
+	return json.Marshal(fmt.Sprintf("%04o", uint32(m)))
+}
+
+// or returns m, or what the archive says when m is unset. Archives without
+// permissions fall back to def.
+func (m fileMode) or(archived, def os.FileMode) os.FileMode {
+	switch {
+	case m != 0:
+		return os.FileMode(m)
+	case archived.Perm() != 0:
+		return archived.Perm()
+	}
+	return def
+}
+
+// setMode applies an explicit FileMode or DirMode, which unlike the create
+// mode isn't narrowed by the umask.
+func setMode(path string, m fileMode) error {
+	if m == 0 {
+		return nil
+	}
+	return errors.Wrapf(os.Chmod(path, os.FileMode(m)), "cannot set the mode of %s", path)
+}
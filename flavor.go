@@ -0,0 +1,78 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// flavorTOCSuffixes maps each game flavor to the suffix of its TOC file.
+var flavorTOCSuffixes = map[string]string{
+	"retail":  "_Mainline",
+	"classic": "_Vanilla",
+	"tbc":     "_TBC",
+	"wrath":   "_Wrath",
+	"cata":    "_Cata",
+	"mists":   "_Mists",
+}
+
+// flavorAliases accepts the names other tools use for the same flavors.
+var flavorAliases = map[string]string{
+	"mainline": "retail",
+	"vanilla":  "classic",
+	"era":      "classic",
+	"bcc":      "tbc",
+	"wotlk":    "wrath",
+}
+
+// normalizeFlavor lowercases and resolves aliases, "" means retail.
+func normalizeFlavor(f string) (string, error) {
+	f = strings.ToLower(strings.TrimSpace(f))
+	if f == "" {
+		return "retail", nil
+	}
+	if alias, ok := flavorAliases[f]; ok {
+		f = alias
+	}
+	if _, ok := flavorTOCSuffixes[f]; !ok {
+		return "", errors.Errorf("unknown flavor %q", f)
+	}
+	return f, nil
+}
+
+// flavorMatches reports whether a build for flavors suits want. Builds that
+// don't advertise flavors suit everything.
+func flavorMatches(flavors []string, want string) bool {
+	if len(flavors) == 0 {
+		return true
+	}
+	want, _ = normalizeFlavor(want)
+	for _, f := range flavors {
+		if f, err := normalizeFlavor(f); err == nil && f == want {
+			return true
+		}
+	}
+	return false
+}
+
+// gameVersionFlavor names the flavor of a client version such as 1.15.2 or
+// 10.2.7 by its major number.
+func gameVersionFlavor(gameVersion string) string {
+	major := gameVersion
+	if i := strings.Index(major, "."); i >= 0 {
+		major = major[:i]
+	}
+	switch major {
+	case "1":
+		return "classic"
+	case "2":
+		return "tbc"
+	case "3":
+		return "wrath"
+	case "4":
+		return "cata"
+	case "5":
+		return "mists"
+	}
+	return "retail"
+}
@@ -6,9 +6,11 @@ require (
 	github.com/PuerkitoBio/goquery v1.4.1
 	github.com/pkg/errors v0.8.0
 	golang.org/x/sys v0.0.0-20181003145944-af653ce8b74f
+	sigs.k8s.io/yaml v1.3.0
 )
 
 require (
 	github.com/andybalholm/cascadia v1.0.0 // indirect
 	golang.org/x/net v0.0.0-20181003013248-f5e5bdd77824 // indirect
+	gopkg.in/yaml.v2 v2.4.0 // indirect
 )
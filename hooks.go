@@ -0,0 +1,41 @@
+package main
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// runHook runs command through the system shell and logs what it prints. The
+// hook environment carries ELVUIUPDATER_ADDON, ELVUIUPDATER_OLD_VERSION and
+// ELVUIUPDATER_NEW_VERSION.
+func (e *elvui) runHook(kind, command string) error {
+	cmd := shellCommand(e.context(), command)
+	cmd.Env = append(os.Environ(),
+		"ELVUIUPDATER_ADDON="+e.Name,
+		"ELVUIUPDATER_OLD_VERSION="+e.localVersion.raw,
+		"ELVUIUPDATER_NEW_VERSION="+e.remoteVersion.raw,
+	)
+	out, err := cmd.CombinedOutput()
+	if output := strings.TrimSpace(string(out)); output != "" {
+		e.infof("%s %s hook: %s\n", e.Name, kind, output)
+	}
+	if err != nil {
+		return errors.Wrapf(err, "%s %s hook failed", e.Name, kind)
+	}
+
+	return nil
+}
+
+// shellCommand runs command through sh, or cmd on Windows.
+func shellCommand(ctx context.Context, command string) *exec.Cmd {
+	shell, flag := "sh", "-c"
+	if runtime.GOOS == "windows" {
+		shell, flag = "cmd", "/C"
+	}
+	return exec.CommandContext(ctx, shell, flag, command)
+}
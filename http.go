@@ -0,0 +1,237 @@
+package main
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// duration reads Go duration strings such as "30s" from the config.
+type duration time.Duration
+
+func (d *duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return errors.Errorf("durations are strings like \"30s\", got %s", b)
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	*d = duration(v)
+	return nil
+}
+
+func (d duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// or returns d, or def when d is unset.
+func (d duration) or(def time.Duration) time.Duration {
+	if d <= 0 {
+		return def
+	}
+	return time.Duration(d)
+}
+
+// parseProxy validates a proxy URL, SOCKS5 ones included.
+func parseProxy(raw string) (*url.URL, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid proxy %s", raw)
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5", "socks5h":
+	default:
+		return nil, errors.Errorf("invalid proxy %s, use an http, https or socks5 URL", raw)
+	}
+	if u.Host == "" {
+		return nil, errors.Errorf("invalid proxy %s, it has no host", raw)
+	}
+	return u, nil
+}
+
+// proxy uses Proxy when configured, else HTTP_PROXY and HTTPS_PROXY, else
+// ALL_PROXY, which is where SOCKS tunnels usually get announced. Proxy and
+// ALL_PROXY apply to every request, loadConfig validated both.
+func (c *configuration) proxy() func(*http.Request) (*url.URL, error) {
+	raw := c.Proxy
+	if raw == "" && os.Getenv("HTTP_PROXY") == "" && os.Getenv("http_proxy") == "" &&
+		os.Getenv("HTTPS_PROXY") == "" && os.Getenv("https_proxy") == "" {
+		raw = allProxy()
+	}
+	if raw == "" {
+		return http.ProxyFromEnvironment
+	}
+	u, _ := parseProxy(raw)
+	return http.ProxyURL(u)
+}
+
+func allProxy() string {
+	if v := os.Getenv("ALL_PROXY"); v != "" {
+		return v
+	}
+	return os.Getenv("all_proxy")
+}
+
+// loadCABundle returns the system roots plus the certificates of the PEM
+// file at path.
+func loadCABundle(path string) (*x509.CertPool, error) {
+	pem, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot read CA bundle %s", path)
+	}
+	pool, err := x509.SystemCertPool()
+	if err != nil || pool == nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, errors.Errorf("no certificate found in CA bundle %s", path)
+	}
+	return pool, nil
+}
+
+// orInt returns n, or def when n is unset.
+func orInt(n, def int) int {
+	if n <= 0 {
+		return def
+	}
+	return n
+}
+
+// orString returns s, or def when s is empty.
+func orString(s, def string) string {
+	if s == "" {
+		return def
+	}
+	return s
+}
+
+// newClient builds the HTTP client shared by every request, so connections
+// to a host are reused across addons. The transport bounds each connection
+// phase on its own; whole requests are bounded by their context instead so a
+// slow body doesn't trip a dial sized timeout.
+func (c *configuration) newClient() *http.Client {
+	transport := &http.Transport{
+		Proxy: c.proxy(),
+		DialContext: (&net.Dialer{
+			Timeout:   c.DialTimeout.or(10 * time.Second),
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		TLSHandshakeTimeout:   c.TLSHandshakeTimeout.or(10 * time.Second),
+		ResponseHeaderTimeout: c.ResponseHeaderTimeout.or(15 * time.Second),
+		IdleConnTimeout:       c.IdleConnTimeout.or(90 * time.Second),
+		MaxIdleConns:          orInt(c.MaxIdleConns, 100),
+		MaxIdleConnsPerHost:   orInt(c.MaxIdleConnsPerHost, 4),
+		MaxConnsPerHost:       c.MaxConnsPerHost,
+		ForceAttemptHTTP2:     true,
+		TLSClientConfig: &tls.Config{
+			RootCAs:            c.rootCAs,
+			InsecureSkipVerify: c.InsecureSkipVerify,
+		},
+	}
+
+	return &http.Client{Transport: transport, CheckRedirect: c.checkRedirect}
+}
+
+// probe checks that the download URL answers with success without fetching
+// the archive. It asks with HEAD and falls back to a GET whose body is left
+// unread when the server doesn't support HEAD.
+func (e *elvui) probe() error {
+	ctx, cancel := context.WithTimeout(e.context(), e.RequestTimeout.or(30*time.Second))
+	defer cancel()
+	resp, err := e.head(ctx, e.downloadURL)
+	if err != nil {
+		return errors.Wrapf(err, "cannot reach %s", e.downloadURL)
+	}
+	resp.Body.Close()
+	logRedirect(e.downloadURL, resp)
+
+	size := "unknown size"
+	if resp.ContentLength >= 0 {
+		size = formatBytes(resp.ContentLength)
+	}
+	e.infof("%s: %s answers %s, %s\n", e.Name, resp.Request.URL, resp.Status, size)
+	if resp.StatusCode/100 != 2 {
+		return errors.Errorf("%s answers %s", e.downloadURL, resp.Status)
+	}
+	return nil
+}
+
+// head asks for url with HEAD, or with a GET whose body the caller leaves
+// unread when the server doesn't support HEAD.
+func (e *elvui) head(ctx context.Context, url string) (*http.Response, error) {
+	resp, err := e.request(ctx, http.MethodHead, url)
+	if err == nil && (resp.StatusCode == http.StatusMethodNotAllowed || resp.StatusCode == http.StatusNotImplemented) {
+		resp.Body.Close()
+		resp, err = e.request(ctx, http.MethodGet, url)
+	}
+	return resp, err
+}
+
+func (e *elvui) request(ctx context.Context, method, url string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, nil)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return e.do(req)
+}
+
+// decodedBody reads resp without its Content-Encoding. The transport only
+// decodes the gzip it asked for itself, which an explicit Accept-Encoding
+// turns off, so get never passes one on and whatever encoding a server
+// still sends is decoded here.
+func decodedBody(resp *http.Response) (io.Reader, error) {
+	switch encoding := strings.ToLower(resp.Header.Get("Content-Encoding")); encoding {
+	case "", "identity":
+		return resp.Body, nil
+	case "gzip", "x-gzip":
+		r, err := gzip.NewReader(resp.Body)
+		if err != nil {
+			return nil, errors.Wrap(err, "cannot decode gzip response")
+		}
+		return r, nil
+	case "deflate":
+		r, err := zlib.NewReader(resp.Body)
+		if err != nil {
+			return nil, errors.Wrap(err, "cannot decode deflate response")
+		}
+		return r, nil
+	default:
+		return nil, errors.Errorf("unsupported Content-Encoding %s", encoding)
+	}
+}
+
+// checkHeader rejects header names that aren't HTTP tokens and values that
+// would break the request. Accept-Encoding is left to the transport, see
+// decodedBody.
+func checkHeader(name, value string) error {
+	if name == "" || strings.IndexFunc(name, func(r rune) bool { return !isTokenChar(r) }) >= 0 {
+		return errors.Errorf("invalid header name %q", name)
+	}
+	if http.CanonicalHeaderKey(name) == "Accept-Encoding" {
+		return errors.New("header Accept-Encoding can't be set, compression is negotiated by the client")
+	}
+	if strings.ContainsAny(value, "\r\n\x00") {
+		return errors.Errorf("header %s has a line break or NUL in its value", name)
+	}
+	return nil
+}
+
+// isTokenChar reports whether r may appear in a header name (RFC 7230).
+func isTokenChar(r rune) bool {
+	return r < 0x7f && (r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("!#$%&'*+-.^_`|~", r))
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"embed"
+	"encoding/json"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// localeFiles map each English message, format verbs included, to its
+// translation. Adding a language is adding a file named after its locale.
+//
+//go:embed locales/*.json
+var localeFiles embed.FS
+
+// catalog holds the messages of the selected language, nil for English.
+var catalog map[string]string
+
+// setupLocale loads the catalog for lang, falling back to LC_ALL,
+// LC_MESSAGES and LANG. pt_BR.UTF-8 tries pt_BR, then pt. Only a language
+// asked for with -lang must exist, the environment is a mere preference.
+func setupLocale(lang string) error {
+	explicit := lang != ""
+	for _, name := range []string{"LC_ALL", "LC_MESSAGES", "LANG"} {
+		if lang != "" {
+			break
+		}
+		lang = os.Getenv(name)
+	}
+	lang = strings.SplitN(strings.SplitN(lang, ".", 2)[0], "@", 2)[0]
+	if lang == "" || lang == "C" || lang == "POSIX" || strings.HasPrefix(lang, "en") {
+		return nil
+	}
+
+	for _, name := range []string{lang, strings.SplitN(lang, "_", 2)[0]} {
+		raw, err := localeFiles.ReadFile("locales/" + name + ".json")
+		if err != nil {
+			continue
+		}
+		if err := json.Unmarshal(raw, &catalog); err != nil {
+			return errors.Wrapf(err, "cannot parse locale %s", name)
+		}
+		return nil
+	}
+	if !explicit {
+		return nil
+	}
+	return errors.Errorf("no translation for %s, using English", lang)
+}
+
+// tr translates an English message, unknown ones are returned as is.
+func tr(s string) string {
+	if t, ok := catalog[s]; ok {
+		return t
+	}
+	return s
+}
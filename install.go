@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// discoverInstalls lists the game folder the registry names followed by its
+// siblings, such as _classic_ or _ptr_ next to _retail_, that hold an
+// Interface folder or a game executable.
+func discoverInstalls(hive, keyPath string) ([]string, error) {
+	dir, err := registryInstall(hive, keyPath)
+	if err != nil {
+		return nil, err
+	}
+	if !isDir(dir) {
+		// left behind when the game was moved or uninstalled
+		return nil, errors.Errorf("WoW install path is stale or missing: %s, the registry still points there, set InstallPath to the game folder", dir)
+	}
+	installs := []string{dir}
+	root := filepath.Dir(dir)
+	entries, err := ioutil.ReadDir(root)
+	if err != nil {
+		return installs, nil
+	}
+	for _, entry := range entries {
+		name, path := entry.Name(), filepath.Join(root, entry.Name())
+		if !entry.IsDir() || path == dir || len(name) < 3 || !strings.HasPrefix(name, "_") || !strings.HasSuffix(name, "_") {
+			continue
+		}
+		if isGameDir(path) {
+			installs = append(installs, path)
+		}
+	}
+	return installs, nil
+}
+
+func isGameDir(dir string) bool {
+	if info, err := os.Stat(filepath.Join(dir, "Interface")); err == nil && info.IsDir() {
+		return true
+	}
+	matches, _ := filepath.Glob(filepath.Join(dir, "Wow*.exe"))
+	return len(matches) > 0
+}
+
+// pickInstall returns the install named by its folder, e.g. _ptr_, when name
+// is set. Otherwise a single install is taken as is and the user picks among
+// several when stdin is a terminal. A run updates one install: the state of
+// an addon, its manifest and -resume checkpoint, is kept by name alone.
+func pickInstall(installs []string, name string) (string, error) {
+	if strings.EqualFold(name, "all") {
+		return "", errors.Errorf("-install all is not supported, run once per install (%s) with -install", strings.Join(installs, ", "))
+	}
+	if name != "" {
+		for _, dir := range installs {
+			if strings.EqualFold(filepath.Base(dir), name) {
+				return dir, nil
+			}
+		}
+		return "", errors.Errorf("no WoW install named %s among %s", name, strings.Join(installs, ", "))
+	}
+	if len(installs) == 1 {
+		return installs[0], nil
+	}
+	if info, err := os.Stdin.Stat(); err != nil || info.Mode()&os.ModeCharDevice == 0 {
+		return "", errors.Errorf("found several WoW installs (%s), pick one with -install or set InstallPath", strings.Join(installs, ", "))
+	}
+
+	for i, dir := range installs {
+		fmt.Fprintf(os.Stderr, "%d) %s\n", i+1, dir)
+	}
+	reader := bufio.NewReader(os.Stdin)
+	for {
+		fmt.Fprintf(os.Stderr, "Install to update [1-%d]: ", len(installs))
+		line, err := reader.ReadString('\n')
+		if n, convErr := strconv.Atoi(strings.TrimSpace(line)); convErr == nil && n >= 1 && n <= len(installs) {
+			return installs[n-1], nil
+		}
+		if err != nil {
+			return "", errors.Wrap(err, "no install picked")
+		}
+	}
+}
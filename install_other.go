@@ -0,0 +1,11 @@
+//go:build !windows
+// +build !windows
+
+package main
+
+import "github.com/pkg/errors"
+
+// registryInstall has no registry to consult outside Windows.
+func registryInstall(hive, keyPath string) (string, error) {
+	return "", errors.New("cannot find WoW install directory, set InstallPath in the config")
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPickInstall(t *testing.T) {
+	root := filepath.Join("Games", "World of Warcraft")
+	retail, classic := filepath.Join(root, "_retail_"), filepath.Join(root, "_classic_")
+	installs := []string{retail, classic}
+
+	tests := []struct {
+		name, pick string
+		installs   []string
+		want       string
+		wantErr    string
+	}{
+		{name: "named", installs: installs, pick: "_Classic_", want: classic},
+		{name: "unknown", installs: installs, pick: "_ptr_", wantErr: "no WoW install named _ptr_"},
+		{name: "all", installs: installs, pick: "all", wantErr: "-install all is not supported"},
+		{name: "single", installs: installs[:1], want: retail},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := pickInstall(tt.installs, tt.pick)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("got %q, %v, want an error containing %q", got, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("got %q, %v, want %q", got, err, tt.want)
+			}
+		})
+	}
+}
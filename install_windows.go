@@ -0,0 +1,71 @@
+//go:build windows
+// +build windows
+
+package main
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	"golang.org/x/sys/windows/registry"
+)
+
+const (
+	defaultRegistryHive    = "LOCAL_MACHINE"
+	defaultRegistryKeyPath = `SOFTWARE\Wow6432Node\Blizzard Entertainment\World of Warcraft`
+)
+
+// registryValueNames are tried in order, installs from different launcher
+// versions name the value differently.
+var registryValueNames = []string{"InstallPath", "InstallLocation", "GamePath"}
+
+// registryHives accepts both the short and the HKEY_ spelling of each hive.
+var registryHives = map[string]registry.Key{
+	"LOCAL_MACHINE":  registry.LOCAL_MACHINE,
+	"HKLM":           registry.LOCAL_MACHINE,
+	"CURRENT_USER":   registry.CURRENT_USER,
+	"HKCU":           registry.CURRENT_USER,
+	"CLASSES_ROOT":   registry.CLASSES_ROOT,
+	"HKCR":           registry.CLASSES_ROOT,
+	"USERS":          registry.USERS,
+	"HKU":            registry.USERS,
+	"CURRENT_CONFIG": registry.CURRENT_CONFIG,
+	"HKCC":           registry.CURRENT_CONFIG,
+}
+
+// registryInstall resolves the game folder, such as _retail_, from the WoW
+// install registry key, hive and keyPath default to where Blizzard keeps it
+// today.
+func registryInstall(hive, keyPath string) (string, error) {
+	if hive == "" {
+		hive = defaultRegistryHive
+	}
+	if keyPath == "" {
+		keyPath = defaultRegistryKeyPath
+	}
+	root, ok := registryHives[strings.TrimPrefix(strings.ToUpper(hive), "HKEY_")]
+	if !ok {
+		return "", errors.Errorf("unknown registry hive %s", hive)
+	}
+
+	k, err := registry.OpenKey(root, keyPath, registry.QUERY_VALUE)
+	if err != nil {
+		return "", errors.Wrap(err, "cannot find WoW install directory")
+	}
+	defer k.Close()
+
+	var tried []string
+	for _, name := range registryValueNames {
+		s, _, err := k.GetStringValue(name)
+		if err == nil && s != "" {
+			return filepath.Clean(s), nil
+		}
+		if err == nil {
+			err = errors.New("empty value")
+		}
+		tried = append(tried, name+": "+err.Error())
+	}
+
+	return "", errors.Errorf("cannot find WoW install directory in %s\\%s (%s)", hive, keyPath, strings.Join(tried, ", "))
+}
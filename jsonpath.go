@@ -0,0 +1,50 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// checkJSONPath rejects dot paths with empty segments, like "data..version".
+func checkJSONPath(path string) error {
+	if path == "" {
+		return nil
+	}
+	for _, segment := range strings.Split(path, ".") {
+		if segment == "" {
+			return errors.Errorf("invalid path %q, empty segment", path)
+		}
+	}
+	return nil
+}
+
+// lookupJSONPath walks the dot path through decoded JSON and returns the
+// string found there. Numeric segments index arrays, "data.0.version".
+func lookupJSONPath(doc interface{}, path string) (string, error) {
+	v := doc
+	for _, segment := range strings.Split(path, ".") {
+		switch node := v.(type) {
+		case map[string]interface{}:
+			next, ok := node[segment]
+			if !ok {
+				return "", errors.Errorf("%s: no field %q", path, segment)
+			}
+			v = next
+		case []interface{}:
+			i, err := strconv.Atoi(segment)
+			if err != nil || i < 0 || i >= len(node) {
+				return "", errors.Errorf("%s: no index %q in an array of %d", path, segment, len(node))
+			}
+			v = node[i]
+		default:
+			return "", errors.Errorf("%s: %q is not inside an object or array", path, segment)
+		}
+	}
+	s, ok := v.(string)
+	if !ok {
+		return "", errors.Errorf("%s: not a string but %T", path, v)
+	}
+	return s, nil
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// keychainService namespaces our entries in the OS credential store.
+const keychainService = "elvuiUpdater"
+
+// credentialProviders are the providers that accept a stored credential.
+var credentialProviders = []string{"github", "curseforge", "wago"}
+
+// login stores the provider credential in the keychain, asking for it on the
+// terminal.
+func login(provider string) error {
+	provider = strings.ToLower(provider)
+	if !containsFold(credentialProviders, provider) {
+		return errors.Errorf("provider %s takes no credential, expected one of %v", provider, credentialProviders)
+	}
+	return keychainLogin(provider)
+}
+
+// promptSecret asks for the provider credential on stderr and reads it from
+// stdin, not echoed when stdin is a terminal.
+func promptSecret(provider string) (string, error) {
+	fmt.Fprintf(os.Stderr, "%s credential: ", provider)
+	restore := noEcho(os.Stdin)
+	secret, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	restore()
+	// the Enter wasn't echoed either
+	fmt.Fprintln(os.Stderr)
+	if err != nil && secret == "" {
+		return "", errors.Wrap(err, "cannot read credential")
+	}
+	secret = strings.TrimSpace(secret)
+	if secret == "" {
+		return "", errors.New("empty credential")
+	}
+	return secret, nil
+}
+
+// credentialsUsed tells which credentialProviders some addon needs. Nightly
+// builds come from the GitHub API whatever the provider.
+func (c *configuration) credentialsUsed() map[string]bool {
+	used := map[string]bool{}
+	for _, a := range c.addons() {
+		if provider := strings.ToLower(a.Provider); containsFold(credentialProviders, provider) {
+			used[provider] = true
+		}
+		if channel, _ := normalizeChannel(a.Channel); channel == "nightly" {
+			used["github"] = true
+		}
+	}
+	return used
+}
+
+// fromKeychain fills *secret from the keychain when it is still empty and
+// reports whether it did.
+func fromKeychain(provider string, secret *string) bool {
+	if *secret != "" {
+		return false
+	}
+	s, err := keychainGet(provider)
+	if err != nil {
+		verbosef("Keychain lookup for %s failed: %v\n", provider, err)
+		return false
+	}
+	*secret = s
+	return s != ""
+}
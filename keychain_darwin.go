@@ -0,0 +1,35 @@
+//go:build darwin
+// +build darwin
+
+package main
+
+import (
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// keychainGet reads the provider secret from the macOS Keychain, "" when none
+// is stored.
+func keychainGet(provider string) (string, error) {
+	out, err := exec.Command("security", "find-generic-password", "-s", keychainService, "-a", provider, "-w").Output()
+	if exitErr, ok := err.(*exec.ExitError); ok && exitErr.ExitCode() == 44 {
+		return "", nil
+	} else if err != nil {
+		return "", errors.Wrapf(err, "cannot read %s credential", provider)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// keychainLogin stores the provider secret in the macOS Keychain. security
+// asks for it itself, a secret on its command line would show in ps.
+func keychainLogin(provider string) error {
+	cmd := exec.Command("security", "add-generic-password", "-U", "-s", keychainService, "-a", provider, "-w")
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stderr, os.Stderr
+	if err := cmd.Run(); err != nil {
+		return errors.Wrapf(err, "cannot store %s credential", provider)
+	}
+	return nil
+}
@@ -0,0 +1,45 @@
+//go:build !windows && !darwin
+// +build !windows,!darwin
+
+package main
+
+import (
+	"os/exec"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// keychainGet reads the provider secret through libsecret's secret-tool, ""
+// when none is stored.
+func keychainGet(provider string) (string, error) {
+	if _, err := exec.LookPath("secret-tool"); err != nil {
+		return "", nil
+	}
+	out, err := exec.Command("secret-tool", "lookup", "service", keychainService, "provider", provider).Output()
+	if exitErr, ok := err.(*exec.ExitError); ok && exitErr.ExitCode() == 1 {
+		return "", nil
+	} else if err != nil {
+		return "", errors.Wrapf(err, "cannot read %s credential", provider)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// keychainLogin prompts for the provider secret and stores it.
+func keychainLogin(provider string) error {
+	secret, err := promptSecret(provider)
+	if err != nil {
+		return err
+	}
+	return keychainSet(provider, secret)
+}
+
+// keychainSet stores the provider secret through libsecret's secret-tool.
+func keychainSet(provider, secret string) error {
+	cmd := exec.Command("secret-tool", "store", "--label", keychainService+" "+provider, "service", keychainService, "provider", provider)
+	cmd.Stdin = strings.NewReader(secret)
+	if err := cmd.Run(); err != nil {
+		return errors.Wrapf(err, "cannot store %s credential", provider)
+	}
+	return nil
+}
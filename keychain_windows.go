@@ -0,0 +1,95 @@
+//go:build windows
+// +build windows
+
+package main
+
+import (
+	"syscall"
+	"unsafe"
+
+	"github.com/pkg/errors"
+	"golang.org/x/sys/windows"
+)
+
+var (
+	advapi32      = windows.NewLazySystemDLL("advapi32.dll")
+	procCredRead  = advapi32.NewProc("CredReadW")
+	procCredWrite = advapi32.NewProc("CredWriteW")
+	procCredFree  = advapi32.NewProc("CredFree")
+)
+
+const (
+	credTypeGeneric         = 1
+	credPersistLocalMachine = 2
+	errorNotFound           = syscall.Errno(1168)
+)
+
+// credential mirrors CREDENTIALW from wincred.h.
+type credential struct {
+	Flags              uint32
+	Type               uint32
+	TargetName         *uint16
+	Comment            *uint16
+	LastWritten        windows.Filetime
+	CredentialBlobSize uint32
+	CredentialBlob     *byte
+	Persist            uint32
+	AttributeCount     uint32
+	Attributes         uintptr
+	TargetAlias        *uint16
+	UserName           *uint16
+}
+
+// keychainGet reads the provider secret from the Windows Credential Manager,
+// "" when none is stored.
+func keychainGet(provider string) (string, error) {
+	target, err := windows.UTF16PtrFromString(keychainService + ":" + provider)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+
+	var cred *credential
+	r, _, err := procCredRead.Call(uintptr(unsafe.Pointer(target)), credTypeGeneric, 0, uintptr(unsafe.Pointer(&cred)))
+	if r == 0 {
+		if err == errorNotFound {
+			return "", nil
+		}
+		return "", errors.Wrapf(err, "cannot read %s credential", provider)
+	}
+	defer procCredFree.Call(uintptr(unsafe.Pointer(cred)))
+
+	return string(unsafe.Slice(cred.CredentialBlob, cred.CredentialBlobSize)), nil
+}
+
+// keychainLogin prompts for the provider secret and stores it.
+func keychainLogin(provider string) error {
+	secret, err := promptSecret(provider)
+	if err != nil {
+		return err
+	}
+	return keychainSet(provider, secret)
+}
+
+// keychainSet stores the provider secret in the Windows Credential Manager.
+func keychainSet(provider, secret string) error {
+	target, err := windows.UTF16PtrFromString(keychainService + ":" + provider)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	blob := []byte(secret)
+	cred := credential{
+		Type:               credTypeGeneric,
+		TargetName:         target,
+		CredentialBlobSize: uint32(len(blob)),
+		Persist:            credPersistLocalMachine,
+	}
+	if len(blob) > 0 {
+		cred.CredentialBlob = &blob[0]
+	}
+	if r, _, err := procCredWrite.Call(uintptr(unsafe.Pointer(&cred)), 0); r == 0 {
+		return errors.Wrapf(err, "cannot store %s credential", provider)
+	}
+
+	return nil
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"archive/zip"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// localRelease stands in for the provider with -archive, the version comes
+// from the TOC inside the archive.
+func (e *elvui) localRelease() error {
+	f, err := os.Open(e.localArchive)
+	if err != nil {
+		return errors.Wrapf(err, "cannot open archive %s", e.localArchive)
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return errors.Wrapf(err, "cannot stat archive %s", e.localArchive)
+	}
+
+	e.downloadURL, e.checksum = e.localArchive, ""
+	r, err := e.openArchive(f, info.Size())
+	if err != nil {
+		return err
+	}
+	toc := e.archiveTOC(r)
+	if toc == nil {
+		return errors.Errorf("archive %s has no %s TOC for %s", e.localArchive, e.tocName(), e.Name)
+	}
+	rc, err := toc.Open()
+	if err != nil {
+		return errors.Wrapf(err, "cannot open %s inside %s", toc.Name, e.localArchive)
+	}
+	defer rc.Close()
+	e.remoteVersion, err = e.readTOCVersion(rc, e.localArchive+": "+toc.Name)
+	return err
+}
+
+// archiveTOC finds the addon TOC in r, preferring the configured flavor.
+func (e *elvui) archiveTOC(r *zip.Reader) *zip.File {
+	base := e.folderName() + "/" + e.tocName()
+	var fallback *zip.File
+	for _, f := range r.File {
+		for flavor, suffix := range flavorTOCSuffixes {
+			if !strings.EqualFold(f.Name, base+suffix+".toc") {
+				continue
+			}
+			if flavor == e.flavor() {
+				return f
+			}
+			fallback = f
+		}
+	}
+	return fallback
+}
+
+// copyLocalArchive copies the -archive file to a temp file, which the caller
+// removes like a download, leaving the original alone.
+func (e *elvui) copyLocalArchive() (*os.File, int64, error) {
+	src, err := os.Open(e.localArchive)
+	if err != nil {
+		return nil, 0, errors.Wrapf(err, "cannot open archive %s", e.localArchive)
+	}
+	defer src.Close()
+
+	archive, err := ioutil.TempFile(e.TempDir, "elvuiUpdater-*.zip")
+	if err != nil {
+		return nil, 0, errors.Wrap(err, "cannot create temp file")
+	}
+	size, err := io.Copy(archive, src)
+	if err != nil {
+		archive.Close()
+		os.Remove(archive.Name())
+		return nil, 0, errors.Wrapf(err, "cannot copy archive %s", e.localArchive)
+	}
+	return archive, size, nil
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+)
+
+// verbose enables extra diagnostics such as resolved redirect targets.
+var verbose bool
+
+type logLevel int
+
+const (
+	levelInfo logLevel = iota
+	levelWarn
+	levelError
+	levelSilent
+)
+
+// level is the least severe kind of message that gets logged, set by -q,
+// -qq and -silent.
+var level = levelInfo
+
+// colorOutput is decided once by setupColor, every colored message goes
+// through paint so the decision lives in one place.
+var colorOutput bool
+
+// interactive is set when the log goes to a terminal that understands ANSI
+// sequences, progress is only drawn then.
+var interactive bool
+
+const (
+	colorRed    = "31"
+	colorGreen  = "32"
+	colorYellow = "33"
+)
+
+// setupColor enables ANSI colors unless -no-color or NO_COLOR ask otherwise
+// or the log doesn't go to a terminal.
+func setupColor(noColor bool) {
+	interactive = enableTerminalColor(os.Stderr)
+	colorOutput = !noColor && os.Getenv("NO_COLOR") == "" && interactive
+}
+
+func paint(color, s string) string {
+	if !colorOutput {
+		return s
+	}
+	return "\x1b[" + color + "m" + s + "\x1b[0m"
+}
+
+func verbosef(format string, v ...interface{}) {
+	if verbose && level <= levelInfo {
+		log.Printf(tr(format), v...)
+	}
+}
+
+func infof(format string, v ...interface{}) {
+	if level <= levelInfo {
+		log.Printf(tr(format), v...)
+	}
+}
+
+func warnf(format string, v ...interface{}) {
+	if level <= levelWarn {
+		log.Print(paint(colorYellow, tr("Warning: ")) + fmt.Sprintf(tr(format), v...))
+	}
+}
+
+func errorf(format string, v ...interface{}) {
+	if level <= levelError {
+		log.Print(paint(colorRed, tr("Error: ")) + fmt.Sprintf(tr(format), v...))
+	}
+}
+
+func fatalf(format string, v ...interface{}) {
+	if level <= levelError {
+		log.Print(paint(colorRed, tr("Fatal: ")) + fmt.Sprintf(tr(format), v...))
+	}
+}
+
+// The methods of an addon write to its own log.
+
+func (e *elvui) verbosef(format string, v ...interface{}) {
+	if verbose && level <= levelInfo {
+		e.log.Printf(tr(format), v...)
+	}
+}
+
+func (e *elvui) infof(format string, v ...interface{}) {
+	if level <= levelInfo {
+		e.log.Printf(tr(format), v...)
+	}
+}
+
+func (e *elvui) warnf(format string, v ...interface{}) {
+	if level <= levelWarn {
+		e.log.Print(paint(colorYellow, tr("Warning: ")) + fmt.Sprintf(tr(format), v...))
+	}
+}
+
+func (e *elvui) errorf(format string, v ...interface{}) {
+	if level <= levelError {
+		e.log.Print(paint(colorRed, tr("Error: ")) + fmt.Sprintf(tr(format), v...))
+	}
+}
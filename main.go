@@ -4,210 +4,2235 @@ import (
 	"archive/zip"
 	"bufio"
 	"bytes"
+	"context"
+	"crypto/md5"
+	"crypto/x509"
+	_ "embed"
+	"encoding/hex"
 	"encoding/json"
 	"flag"
+	"fmt"
 	"io"
 	"io/ioutil"
 	"log"
+	"math/rand"
 	"net/http"
+	"net/url"
 	"os"
+	"path"
 	"path/filepath"
-	"strconv"
+	"reflect"
+	"sort"
 	"strings"
+	"sync"
 	"time"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/pkg/errors"
-	"golang.org/x/sys/windows/registry"
+	"sigs.k8s.io/yaml"
 )
 
 type APIResponse struct {
 	URL     string `json:"url"`
 	Version string `json:"version"`
+	// Flavor is only set by endpoints listing several variants
+	Flavor string `json:"flavor"`
+	// Slug and Name identify the addon on endpoints listing several addons
+	Slug string `json:"slug"`
+	Name string `json:"name"`
 }
 
-type configuration struct {
+// addonConfig describes one addon to keep up to date.
+type addonConfig struct {
+	Name        string
 	Page        string
 	Directories []string
-	addon       string
+	// FlavorPathPrefix is the archive folder holding the build to install,
+	// e.g. _classic_, for packages that ship every flavor side by side.
+	FlavorPathPrefix string
+	// ExtractInto is the AddOns subfolder receiving archives that have their
+	// files at the root instead of inside a folder.
+	ExtractInto string
+	// ImportDefaultProfile copies DefaultProfile, a SavedVariables file
+	// relative to AddOns, into each WTF account lacking it on first install.
+	ImportDefaultProfile bool
+	DefaultProfile       string
+	// FolderName is the AddOns folder holding the TOC, Name by default.
+	// TOCName is the TOC base name without flavor suffix, FolderName by
+	// default.
+	FolderName string
+	TOCName    string
+	// Provider picks where versions come from: tukui (default, reads Page),
+	// wowinterface, curseforge or wago (read AddonID), github (reads Repo)
+	// or exec (runs ResolveCommand).
+	Provider string
+	AddonID  string
+	Repo     string
+	// Flavor is the game flavor to install for: retail (default), classic,
+	// tbc, wrath, cata or mists. It picks the TOC file and, when a provider
+	// lists several builds, the highest version built for that flavor.
+	Flavor string
+	// Channel is the release channel for providers that have several:
+	// stable (default), beta or alpha.
+	Channel string
+	// AutoDirectories derives the cleanup set from the archive's top-level
+	// folders instead of trusting Directories.
+	AutoDirectories bool
+	// Companions are AddOns folders released along with the addon's own,
+	// e.g. ElvUI_Options: an archive lacking one is refused, all of them
+	// are replaced together and their TOC must report the same version.
+	Companions []string
+	// Mirrors are base URLs serving the same archives, tried in order when
+	// the download from the provider fails.
+	Mirrors []string
+	// Headers are sent with every provider request of the addon, e.g. an
+	// Accept version or a Referer. Their values may be secrets and are
+	// never logged.
+	Headers map[string]string
+	// Enabled set to false leaves the addon out of every run unless it is
+	// named with -only.
+	Enabled *bool
+	// ManifestURL points at a JSON object mapping each archive path to its
+	// SHA-256, the extracted files must match it before anything is replaced.
+	ManifestURL string
+	// PagePattern extracts the version and url from Page for the html
+	// provider.
+	PagePattern string
+	// ResolveCommand is the shell command of the exec provider, see
+	// execProvider for what it gets and must print.
+	ResolveCommand string
+	// VersionPath and URLPath are dot paths to the version and url of a
+	// tukui provider response nesting them, "data.latest.version".
+	VersionPath string
+	URLPath     string
+	// VersionPrefix labels the version line of the TOC, "## Version:" by
+	// default. Whitespace and case don't matter.
+	VersionPrefix string
+	// VersionScheme is how versions compare: numeric, semver or date, detected
+	// from each version by default.
+	VersionScheme string
+	// MinVersion refuses releases older than it, whatever the provider says.
+	MinVersion string
+	// PreUpdateHook is a shell command run before installing, a non-zero
+	// exit skips the update of this addon.
+	PreUpdateHook string
+	// PostUpdateHook is a shell command run after a successful update, see
+	// runHook for the environment of both hooks. Its failure is only logged
+	// unless FailOnHookError is set.
+	PostUpdateHook  string
+	FailOnHookError bool
+}
+
+type configuration struct {
+	// the top-level addon fields describe ElvUI for configs without Addons
+	addonConfig
+	Addons []addonConfig
+	// Defaults fills every setting an addon leaves empty
+	Defaults addonConfig
+	// Profiles name sets of addons updated together with -profile
+	Profiles map[string][]string
+	// InstallPath is the WoW game folder (the one holding Interface), it
+	// replaces the registry lookup and is required outside Windows.
+	InstallPath string
+	// RegistryHive and RegistryKeyPath locate the WoW install key on Windows,
+	// defaulting to LOCAL_MACHINE and the Wow6432Node Blizzard key.
+	RegistryHive    string
+	RegistryKeyPath string
+	// TempDir holds downloaded archives and the staging tree, defaults to the
+	// system temp dir. Keep it on the same volume as WoW so moves are renames.
+	TempDir string
+	// DialTimeout, TLSHandshakeTimeout and ResponseHeaderTimeout bound the
+	// connection phases (default 10s, 10s, 15s). RequestTimeout bounds a whole
+	// API call (default 30s) and DownloadTimeout an archive download
+	// (default 10m). Timeout bounds the whole run, no limit by default.
+	DialTimeout           duration
+	TLSHandshakeTimeout   duration
+	ResponseHeaderTimeout duration
+	RequestTimeout        duration
+	DownloadTimeout       duration
+	Timeout               duration
+	// MaxIdleConns and MaxIdleConnsPerHost bound the connections kept open
+	// for reuse (default 100 and 4), IdleConnTimeout how long they stay
+	// (default 90s). MaxConnsPerHost caps the connections to one host, no
+	// limit by default.
+	MaxIdleConns        int
+	MaxIdleConnsPerHost int
+	MaxConnsPerHost     int
+	IdleConnTimeout     duration
+	// CABundle is a PEM file of extra certificate authorities to trust, e.g.
+	// the one of a TLS intercepting proxy. InsecureSkipVerify turns
+	// certificate checks off altogether, only as a last resort.
+	CABundle           string
+	InsecureSkipVerify bool
+	// Proxy is an http, https or socks5 URL used for every request instead
+	// of the proxy environment variables.
+	Proxy string
+	// ClearCache removes the game's Cache folder after an update installed
+	// something. SavedVariables under WTF are never touched.
+	ClearCache bool
+	// NotifyOn silences runs with nothing to report: always (default),
+	// changed or error. NotifyCommand runs whenever a run is reported, see
+	// notify for its environment.
+	NotifyOn      string
+	NotifyCommand string
+	// FileMode and DirMode are the octal permissions of extracted files and
+	// folders, e.g. "0640" and "0750". The archive's own apply by default.
+	FileMode fileMode
+	DirMode  fileMode
+	// KeepArchive saves every installed archive into ArchiveDir, the archives
+	// folder of StateDir by default, as <addon>-<version>.zip.
+	KeepArchive bool
+	ArchiveDir  string
+	// StateDir holds state.json and the install manifests, see
+	// defaultStateDir for where it is when unset.
+	StateDir string
+	// MaxRedirects caps how many redirects a request may follow, 0 means 10.
+	MaxRedirects int
+	// Concurrency is how many addons are processed at once, 4 by default and
+	// at most maxConcurrency. 1 processes them one after the other.
+	Concurrency int
+	// provider credentials, GITHUB_TOKEN, CURSEFORGE_API_KEY and WAGO_API_KEY
+	// win over them and the OS keychain (see -login) is used when both are
+	// empty
+	GitHubToken      string
+	CurseForgeAPIKey string
+	WagoAPIKey       string
+	// ProcessNames are the game executables that block an update while
+	// running, defaults to Wow.exe and WowClassic.exe. Add PTR or beta ones.
+	ProcessNames []string
+	allowRunning bool
+	// forceCheck bypasses the response cache and -since, see -force-check-all
+	forceCheck bool
+	// localArchive is the -archive file, installed instead of a download
+	localArchive string
+	// wantVersion is the -version release, installed instead of the latest
+	wantVersion string
+	// waitForClose is how long to wait for the game to exit, 0 fails at once
+	waitForClose time.Duration
+	// processListWarning warns once when the processes cannot be listed
+	processListWarning sync.Once
+	responses          responseCache
+	rootCAs            *x509.CertPool
+	// ctx ends when Timeout runs out, every request and hook derives from it
+	ctx   context.Context
+	addon string
+	// sources tells where settings came from, for -print-config -verbose
+	sources settingSources
 }
 
+// elvui updates a single addon. Its own addonConfig shadows the top-level
+// one promoted through configuration.
 type elvui struct {
-	configuration
+	*configuration
+	addonConfig
 	client       *http.Client
-	localVersion float64
-	localName    string
+	localVersion version
+	// log receives everything about this addon, a buffer with -group-output
+	log    *log.Logger
+	output bytes.Buffer
 
-	remoteVersion float64
+	remoteVersion version
 	downloadURL   string
+	checksum      string
+
+	// run statistics for the final summary
+	downloaded int64
+	extracted  int
+	elapsed    time.Duration
+	err        error
+	// installed records what the last extraction wrote
+	installed manifest
+	// progress, when set, is told how downloads and extractions advance
+	progress func(progressEvent)
+
+	// set by -check when the remote version is newer
+	updateAvailable bool
+	// updated is set once an update was installed
+	updated bool
 }
 
-func (e *elvui) init(configPath string) error {
-	rawConfig, err := ioutil.ReadFile(configPath)
+// readConfig parses the config file, "-" reads it from stdin and "" takes
+// the built-in defaults. Nothing is checked yet, see loadConfig.
+func readConfig(configPath string) (*configuration, error) {
+	var rawConfig []byte
+	var err error
+	switch configPath {
+	case "":
+		infof("No config file found, using the built-in ElvUI defaults\n")
+		rawConfig = defaultConfig
+	case "-":
+		rawConfig, err = ioutil.ReadAll(os.Stdin)
+	default:
+		rawConfig, err = ioutil.ReadFile(configPath)
+	}
 	if err != nil {
-		return errors.Wrapf(err, "cannot read file %s", configPath)
+		return nil, errors.Wrapf(err, "cannot read file %s", configPath)
+	}
+	// YAML goes through JSON so both formats share the same field matching
+	if ext := strings.ToLower(filepath.Ext(configPath)); ext == ".yaml" || ext == ".yml" {
+		if rawConfig, err = yaml.YAMLToJSON(rawConfig); err != nil {
+			return nil, errors.Wrapf(err, "cannot parse YAML config %s", configPath)
+		}
+	}
+	c := &configuration{}
+	if err = json.Unmarshal(rawConfig, c); err != nil {
+		return nil, errors.Wrap(err, "cannot unmarshal config")
 	}
-	if err = json.Unmarshal(rawConfig, e); err != nil {
-		return errors.Wrap(err, "cannot unmarshal config")
+	c.expandEnv()
+	switch configPath {
+	case "":
+		c.recordSources(rawConfig, "built-in defaults")
+	case "-":
+		c.recordSources(rawConfig, "stdin")
+	default:
+		c.recordSources(rawConfig, configPath)
 	}
+	return c, nil
+}
 
-	k, err := registry.OpenKey(registry.LOCAL_MACHINE, `SOFTWARE\Wow6432Node\Blizzard Entertainment\World of Warcraft`, registry.QUERY_VALUE)
+// loadConfig reads the config file, "-" reads it from stdin. install picks
+// the game folder among several, a path replaces InstallPath. outputDir,
+// when set, is used instead of any game's AddOns folder.
+func loadConfig(configPath string, portable bool, install, outputDir string) (*configuration, error) {
+	c, err := readConfig(configPath)
 	if err != nil {
-		return errors.Wrap(err, "cannot find WoW install directory")
+		return nil, err
+	}
+	if token := os.Getenv("GITHUB_TOKEN"); token != "" {
+		c.GitHubToken = token
+		c.setSource("GitHubToken", "env GITHUB_TOKEN")
+	}
+	if key := os.Getenv("CURSEFORGE_API_KEY"); key != "" {
+		c.CurseForgeAPIKey = key
+		c.setSource("CurseForgeAPIKey", "env CURSEFORGE_API_KEY")
+	}
+	if key := os.Getenv("WAGO_API_KEY"); key != "" {
+		c.WagoAPIKey = key
+		c.setSource("WagoAPIKey", "env WAGO_API_KEY")
+	}
+	// every lookup may start a helper or an unlock prompt, only ask for what
+	// some addon uses
+	used := c.credentialsUsed()
+	if used["github"] && fromKeychain("github", &c.GitHubToken) {
+		c.setSource("GitHubToken", "keychain")
+	}
+	if used["curseforge"] && fromKeychain("curseforge", &c.CurseForgeAPIKey) {
+		c.setSource("CurseForgeAPIKey", "keychain")
+	}
+	if used["wago"] && fromKeychain("wago", &c.WagoAPIKey) {
+		c.setSource("WagoAPIKey", "keychain")
 	}
-	defer k.Close()
 
-	s, _, err := k.GetStringValue("InstallPath")
-	if err != nil {
-		return errors.Wrap(err, "cannot find WoW install directory")
+	seen := map[string]bool{}
+	for _, a := range c.addons() {
+		if a.Name == "" {
+			return nil, errors.New("every entry in Addons needs a Name")
+		}
+		if seen[strings.ToLower(a.Name)] {
+			return nil, errors.Errorf("addon %s is configured twice", a.Name)
+		}
+		seen[strings.ToLower(a.Name)] = true
+		if _, err := providerFor(a.Provider); err != nil {
+			return nil, errors.Wrapf(err, "addon %s", a.Name)
+		}
+		if strings.EqualFold(a.Provider, "html") {
+			if _, err := compilePagePattern(a.PagePattern); err != nil {
+				return nil, errors.Wrapf(err, "addon %s", a.Name)
+			}
+		}
+		if strings.EqualFold(a.Provider, "exec") && a.ResolveCommand == "" {
+			return nil, errors.Errorf("addon %s: the exec provider needs a ResolveCommand", a.Name)
+		}
+		if _, err := normalizeFlavor(a.Flavor); err != nil {
+			return nil, errors.Wrapf(err, "addon %s", a.Name)
+		}
+		if ch, err := normalizeChannel(a.Channel); err != nil {
+			return nil, errors.Wrapf(err, "addon %s", a.Name)
+		} else if ch == "nightly" && len(a.Directories) == 0 {
+			return nil, errors.Errorf("addon %s: the nightly channel needs Directories to pick from the repository", a.Name)
+		}
+		for _, mirror := range a.Mirrors {
+			if err := checkURL(mirror); err != nil {
+				return nil, errors.Wrapf(err, "addon %s mirror", a.Name)
+			}
+		}
+		for name, value := range a.Headers {
+			if err := checkHeader(name, value); err != nil {
+				return nil, errors.Wrapf(err, "addon %s", a.Name)
+			}
+		}
+		for _, path := range []string{a.VersionPath, a.URLPath} {
+			if err := checkJSONPath(path); err != nil {
+				return nil, errors.Wrapf(err, "addon %s", a.Name)
+			}
+		}
+		if _, err := normalizeVersionScheme(a.VersionScheme); err != nil {
+			return nil, errors.Wrapf(err, "addon %s", a.Name)
+		}
+		if a.MinVersion != "" {
+			if _, err := parseVersionScheme(a.MinVersion, a.VersionScheme); err != nil {
+				return nil, errors.Wrapf(err, "addon %s MinVersion", a.Name)
+			}
+		}
+	}
+
+	for name, members := range c.Profiles {
+		for _, member := range members {
+			if findAddon(c.addons(), member) < 0 {
+				return nil, errors.Errorf("profile %s: addon %s is not configured", name, member)
+			}
+		}
+	}
+
+	if outputDir != "" {
+		c.addon = outputDir
+		c.setSource("AddOns", "flag -output-dir")
+	} else if portable {
+		if c.addon = portableAddOns(); c.addon != "" {
+			infof("Portable mode, using %s\n", c.addon)
+			c.setSource("AddOns", "flag -portable")
+		} else {
+			infof("Portable install not found, falling back to registry\n")
+		}
+	}
+	if strings.ContainsAny(install, `/\`) {
+		c.InstallPath, install = install, ""
+		c.setSource("InstallPath", "flag -install")
+	}
+	if c.addon == "" && c.InstallPath != "" {
+		c.addon = filepath.Join(c.InstallPath, "Interface", "AddOns")
+		c.setSource("AddOns", "InstallPath")
+	}
+	if c.addon == "" {
+		installs, err := discoverInstalls(c.RegistryHive, c.RegistryKeyPath)
+		if err != nil {
+			return nil, err
+		}
+		dir, err := pickInstall(installs, install)
+		if err != nil {
+			return nil, err
+		}
+		c.addon = filepath.Join(dir, "Interface", "AddOns")
+		c.setSource("AddOns", "registry")
+	}
+
+	for _, a := range c.addons() {
+		for _, dir := range append(append([]string(nil), a.Directories...), a.Companions...) {
+			if !within(c.addon, filepath.Join(c.addon, dir)) {
+				return nil, errors.Errorf("addon %s: directory %q is outside %s", a.Name, dir, c.addon)
+			}
+		}
+		if a.ExtractInto != "" && !within(c.addon, filepath.Join(c.addon, a.ExtractInto)) {
+			return nil, errors.Errorf("addon %s: ExtractInto %q is outside %s", a.Name, a.ExtractInto, c.addon)
+		}
+		if a.ImportDefaultProfile && !within(c.addon, filepath.Join(c.addon, a.DefaultProfile)) {
+			return nil, errors.Errorf("addon %s: ImportDefaultProfile needs a DefaultProfile inside %s", a.Name, c.addon)
+		}
+	}
+
+	if c.NotifyOn, err = normalizeNotifyOn(c.NotifyOn); err != nil {
+		return nil, err
+	}
+
+	for _, raw := range []string{c.Proxy, allProxy()} {
+		if raw == "" {
+			continue
+		}
+		if _, err := parseProxy(raw); err != nil {
+			return nil, err
+		}
+	}
+	if c.CABundle != "" {
+		if c.rootCAs, err = loadCABundle(c.CABundle); err != nil {
+			return nil, err
+		}
+	}
+	if c.InsecureSkipVerify {
+		warnf("InsecureSkipVerify is set, TLS certificates are NOT checked and anyone on the network can tamper with downloads\n")
+	}
+	if err := checkWritable(c.TempDir); err != nil {
+		return nil, errors.Wrap(err, "temp dir is not usable")
 	}
-	e.addon = filepath.Join(s, "Interface", "AddOns")
 
+	return c, nil
+}
+
+// findConfig returns the first existing default config file, "" when there
+// is none so the embedded defaultConfig is used.
+func findConfig() string {
+	for _, name := range []string{"config.json", "config.yaml", "config.yml"} {
+		if _, err := os.Stat(name); err == nil {
+			return name
+		}
+	}
+	return ""
+}
+
+// defaultConfig is used when no config file exists, it updates ElvUI alone.
+//
+//go:embed config.json
+var defaultConfig []byte
+
+// expandEnv substitutes $VAR and ${VAR} in path and URL settings.
+func (c *configuration) expandEnv() {
+	c.InstallPath = os.ExpandEnv(c.InstallPath)
+	c.TempDir = os.ExpandEnv(c.TempDir)
+	c.StateDir = os.ExpandEnv(c.StateDir)
+	c.ArchiveDir = os.ExpandEnv(c.ArchiveDir)
+	c.CABundle = os.ExpandEnv(c.CABundle)
+	c.addonConfig.expandEnv()
+	c.Defaults.expandEnv()
+	for i := range c.Addons {
+		c.Addons[i].expandEnv()
+	}
+}
+
+func (a *addonConfig) expandEnv() {
+	a.Page = os.ExpandEnv(a.Page)
+	a.AddonID = os.ExpandEnv(a.AddonID)
+	a.Repo = os.ExpandEnv(a.Repo)
+	a.ManifestURL = os.ExpandEnv(a.ManifestURL)
+	for i := range a.Mirrors {
+		a.Mirrors[i] = os.ExpandEnv(a.Mirrors[i])
+	}
+}
+
+// addons lists the configured addons merged with Defaults, falling back to
+// the top-level fields as a single ElvUI entry.
+func (c *configuration) addons() []addonConfig {
+	if len(c.Addons) > 0 {
+		addons := make([]addonConfig, len(c.Addons))
+		for i, a := range c.Addons {
+			addons[i] = a.withDefaults(c.Defaults)
+		}
+		return addons
+	}
+	a := c.addonConfig.withDefaults(c.Defaults)
+	if a.Name == "" {
+		a.Name = "ElvUI"
+	}
+	return []addonConfig{a}
+}
+
+// withDefaults fills the zero valued fields of a from d. A zero value means
+// unset, so a false bool can't override a true default.
+func (a addonConfig) withDefaults(d addonConfig) addonConfig {
+	av, dv := reflect.ValueOf(&a).Elem(), reflect.ValueOf(d)
+	for i := 0; i < av.NumField(); i++ {
+		if f := av.Field(i); f.IsZero() {
+			f.Set(dv.Field(i))
+		}
+	}
+	return a
+}
+
+// selectAddons restricts the addons to the names in only (all of them when
+// only is empty) minus the names in skip, matching case-insensitively. The
+// skipped and disabled addons are returned for reporting, naming a disabled
+// addon in only runs it anyway.
+func (c *configuration) selectAddons(only, skip []string) (selected []addonConfig, skipped, disabled []string, err error) {
+	all := c.addons()
+	for _, name := range append(append([]string{}, only...), skip...) {
+		if findAddon(all, name) < 0 {
+			return nil, nil, nil, errors.Errorf("addon %s is not configured", name)
+		}
+	}
+	for _, name := range skip {
+		if containsFold(only, name) {
+			return nil, nil, nil, errors.Errorf("addon %s is both in -only and -skip", name)
+		}
+	}
+
+	for _, a := range all {
+		switch {
+		case containsFold(skip, a.Name):
+			skipped = append(skipped, a.Name)
+		case containsFold(only, a.Name):
+			selected = append(selected, a)
+		case a.Enabled != nil && !*a.Enabled:
+			disabled = append(disabled, a.Name)
+		case len(only) == 0:
+			selected = append(selected, a)
+		}
+	}
+	return selected, skipped, disabled, nil
+}
+
+// profile returns the addons of the named profile, matching the name
+// case-insensitively.
+func (c *configuration) profile(name string) ([]string, error) {
+	for profile, members := range c.Profiles {
+		if strings.EqualFold(profile, name) {
+			return members, nil
+		}
+	}
+	names := make([]string, 0, len(c.Profiles))
+	for profile := range c.Profiles {
+		names = append(names, profile)
+	}
+	sort.Strings(names)
+	return nil, errors.Errorf("profile %s is not configured, expected one of %v", name, names)
+}
+
+func findAddon(addons []addonConfig, name string) int {
+	for i, a := range addons {
+		if strings.EqualFold(a.Name, name) {
+			return i
+		}
+	}
+	return -1
+}
+
+func containsFold(list []string, s string) bool {
+	for _, v := range list {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkRedirect limits the redirect chain and refuses to leave HTTPS.
+func (c *configuration) checkRedirect(req *http.Request, via []*http.Request) error {
+	max := c.MaxRedirects
+	if max <= 0 {
+		max = 10
+	}
+	if len(via) >= max {
+		return errors.Errorf("stopped after %d redirects", max)
+	}
+	if via[0].URL.Scheme == "https" && req.URL.Scheme != "https" {
+		return errors.Errorf("refusing redirect from %s to insecure %s", via[len(via)-1].URL, req.URL)
+	}
 	return nil
 }
 
-func (e *elvui) setRemoteVersionNDownloadURL() error {
-	req, err := http.NewRequest(http.MethodGet, e.Page, nil)
+// logRedirect reports where a request ended up when it was redirected.
+func logRedirect(requested string, resp *http.Response) {
+	if final := resp.Request.URL.String(); final != requested {
+		verbosef("%s redirected to %s\n", requested, final)
+	}
+}
+
+// portableAddOns looks for an AddOns folder next to the executable, returns ""
+// when there is none.
+func portableAddOns() string {
+	exe, err := os.Executable()
 	if err != nil {
-		return errors.WithStack(err)
+		return ""
+	}
+	dir := filepath.Dir(exe)
+
+	candidates := []string{
+		filepath.Join(dir, "Interface", "AddOns"),
+		filepath.Join(dir, "_retail_", "Interface", "AddOns"),
+		filepath.Join(dir, "World of Warcraft", "_retail_", "Interface", "AddOns"),
+	}
+	for _, c := range candidates {
+		if info, err := os.Stat(c); err == nil && info.IsDir() {
+			return c
+		}
+	}
+
+	return ""
+}
+
+// within reports whether path lies strictly inside root.
+func within(root, path string) bool {
+	root, path = filepath.Clean(root), filepath.Clean(path)
+	return strings.HasPrefix(path, root+string(filepath.Separator))
+}
+
+// ensureAddOns creates the AddOns folder of a game that never loaded an addon
+// and makes sure it can be written to. The game folder itself must exist, a
+// wrong path shouldn't leave empty folders around.
+func ensureAddOns(dir string) error {
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		root := filepath.Dir(filepath.Dir(dir))
+		if !isDir(root) {
+			return errors.Errorf("WoW install path is missing: %s, check InstallPath", root)
+		}
+		infof("Creating %s\n", dir)
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return errors.Wrapf(err, "cannot create %s", dir)
+		}
 	}
+	return errors.Wrap(checkWritable(dir), "AddOns is not usable")
+}
+
+// clearGameCache removes the Cache folder of the game in dir, which WoW rebuilds
+// on the next start.
+func clearGameCache(dir string) error {
+	cache := findFold(dir, "Cache")
+	if _, err := os.Stat(cache); os.IsNotExist(err) {
+		return nil
+	}
+	infof("Clearing %s\n", cache)
+	return errors.Wrapf(os.RemoveAll(cache), "cannot clear %s", cache)
+}
 
-	resp, err := e.client.Do(req)
+// checkWritable creates and removes a scratch file inside dir ("" means the
+// system temp dir).
+func checkWritable(dir string) error {
+	f, err := ioutil.TempFile(dir, "elvuiUpdater-")
 	if err != nil {
-		return errors.WithStack(err)
+		return errors.Wrapf(err, "cannot write to %s", dir)
 	}
-	defer resp.Body.Close()
+	f.Close()
+	return os.Remove(f.Name())
+}
 
-	apiResponse := &APIResponse{}
-	if err := json.NewDecoder(resp.Body).Decode(apiResponse); err != nil {
-		return errors.WithStack(err)
+func (e *elvui) setRemoteVersionNDownloadURL() error {
+	if e.localArchive != "" {
+		return e.localRelease()
+	}
+	p, err := providerFor(e.Provider)
+	if err != nil {
+		return err
 	}
 
-	if e.remoteVersion, err = strconv.ParseFloat(apiResponse.Version, 64); err != nil {
-		return errors.Wrapf(err, "cannot parse version number %s", apiResponse.Version)
+	var r release
+	if e.wantVersion != "" {
+		r, err = e.exactRelease(p)
+	} else {
+		r, err = p.latest(e)
+	}
+	if err != nil {
+		return err
 	}
-	e.downloadURL = apiResponse.URL
+	e.remoteVersion, e.downloadURL, e.checksum = r.version, r.url, r.md5
 
 	return nil
 }
 
+// exactRelease asks the provider for the -version release. Providers without
+// a history serve it only when it is their latest.
+func (e *elvui) exactRelease(p provider) (release, error) {
+	want, err := e.parseVersion(e.wantVersion)
+	if err != nil {
+		return release{}, errors.Wrapf(err, "cannot parse version number %s", e.wantVersion)
+	}
+	if h, ok := p.(historic); ok {
+		return h.exact(e, want)
+	}
+	r, err := p.latest(e)
+	if err != nil {
+		return release{}, err
+	}
+	if r.version.compare(want) != 0 {
+		return release{}, errors.Errorf("the %s provider only serves the latest release of %s, %s, not %s", orString(strings.ToLower(e.Provider), "tukui"), e.Name, r.version, want)
+	}
+	return r, nil
+}
+
 func (e *elvui) getLocalVersion() error {
-	prefix := "## Version: "
-	tocFile := filepath.Join(e.addon, e.localName, e.localName+"_Mainline.toc")
+	v, err := e.readLocalVersion()
+	if os.IsNotExist(errors.Cause(err)) {
+		// not installed yet, any release is an update
+		e.verbosef("%s: %v, treating it as not installed\n", e.Name, err)
+		e.localVersion = version{}
+		return nil
+	} else if err != nil {
+		return err
+	}
+	e.localVersion = v
+	return nil
+}
+
+// cutLabel strips label from the start of line, ignoring whitespace and case
+// since spacing around TOC labels varies between addons.
+func cutLabel(line, label string) (string, bool) {
+	for _, r := range label {
+		if unicode.IsSpace(r) {
+			continue
+		}
+		line = strings.TrimLeftFunc(line, unicode.IsSpace)
+		c, size := utf8.DecodeRuneInString(line)
+		if size == 0 || unicode.ToLower(c) != unicode.ToLower(r) {
+			return "", false
+		}
+		line = line[size:]
+	}
+	return line, true
+}
+
+// readLocalVersion parses the version out of the installed TOC file.
+func (e *elvui) readLocalVersion() (version, error) {
+	tocFile := e.tocFile()
 
 	toc, err := os.Open(tocFile)
 	if err != nil {
-		return errors.Wrapf(err, "cannot open file %s", tocFile)
+		return version{}, errors.Wrapf(err, "cannot open file %s", tocFile)
 	}
 	defer toc.Close()
-	tocReader := bufio.NewReader(toc)
+	return e.readTOCVersion(toc, tocFile)
+}
 
-	for {
+// readTOCVersion parses the version line of the TOC read from r, tocFile
+// names it in errors.
+func (e *elvui) readTOCVersion(r io.Reader, tocFile string) (version, error) {
+	prefix := e.VersionPrefix
+	if prefix == "" {
+		prefix = "## Version:"
+	}
+	tocReader := bufio.NewReader(r)
+
+	for first := true; ; first = false {
 		line, err := tocReader.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return version{}, errors.Wrapf(err, "cannot read lines from %s", tocFile)
+		}
+		// editors on Windows like to start UTF-8 files with a BOM
+		if first {
+			line = strings.TrimPrefix(line, "\ufeff")
+		}
+		if rest, ok := cutLabel(line, prefix); ok {
+			// the version is the first word, "13.06 (retail)" annotates it
+			var rawVer string
+			if fields := strings.Fields(rest); len(fields) > 0 {
+				rawVer = fields[0]
+			}
+			v, err := e.parseVersion(rawVer)
+			if err != nil {
+				return version{}, errors.Wrapf(err, "cannot parse version number %s", rawVer)
+			}
+			return v, nil
+		}
 		if err == io.EOF {
 			break
-		} else if err != nil {
-			return errors.Wrapf(err, "cannot read lines from %s", tocFile)
 		}
-		if strings.HasPrefix(line, prefix) {
-			// retard windows need -1
-			rawVer := strings.TrimSpace(line[len(prefix) : len(line)-1])
-			if e.localVersion, err = strconv.ParseFloat(rawVer, 64); err != nil {
-				return errors.Wrapf(err, "cannot parse version number %s", rawVer)
+	}
+
+	return version{}, errors.Errorf("local version not found at %s", tocFile)
+}
+
+// folderName is the AddOns folder holding the TOC, FolderName or Name.
+func (e *elvui) folderName() string {
+	if e.FolderName != "" {
+		return e.FolderName
+	}
+	return e.Name
+}
+
+// tocName is the TOC base name without flavor suffix, TOCName or folderName.
+func (e *elvui) tocName() string {
+	if e.TOCName != "" {
+		return e.TOCName
+	}
+	return e.folderName()
+}
+
+// tocFile locates the TOC of the addon flavor. When it is missing but the TOC
+// of exactly one other flavor exists, that flavor is adopted for the rest of
+// the run, provider queries included.
+func (e *elvui) tocFile() string {
+	dir := findFold(e.addon, e.folderName())
+	expected := findFold(dir, e.tocName()+flavorTOCSuffixes[e.flavor()]+".toc")
+	if _, err := os.Stat(expected); err == nil {
+		return expected
+	}
+
+	var found []string
+	var path string
+	for flavor, suffix := range flavorTOCSuffixes {
+		candidate := findFold(dir, e.tocName()+suffix+".toc")
+		if _, err := os.Stat(candidate); err == nil {
+			found = append(found, flavor)
+			path = candidate
+		}
+	}
+	switch len(found) {
+	case 1:
+		e.infof("%s: no %s TOC, detected the %s flavor from %s\n", e.Name, e.flavor(), found[0], filepath.Base(path))
+		e.Flavor = found[0]
+		return path
+	case 0:
+	default:
+		sort.Strings(found)
+		e.warnf("%s: no %s TOC and several other flavors %v, set Flavor to pick one\n", e.Name, e.flavor(), found)
+	}
+	return expected
+}
+
+// flavor is the normalized Flavor, validated when the config was loaded.
+func (e *elvui) flavor() string {
+	f, _ := normalizeFlavor(e.Flavor)
+	return f
+}
+
+// findFold joins dir and name, matching name case-insensitively against the
+// entries of dir so installs whose folder casing drifted are still found on
+// case-sensitive filesystems. An exact match wins.
+func findFold(dir, name string) string {
+	exact := filepath.Join(dir, name)
+	if _, err := os.Lstat(exact); err == nil {
+		return exact
+	}
+
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return exact
+	}
+	for _, entry := range entries {
+		if strings.EqualFold(entry.Name(), name) {
+			return filepath.Join(dir, entry.Name())
+		}
+	}
+	return exact
+}
+
+// download saves the remote archive into a temp file, caller must remove it.
+// When downloadURL fails the Mirrors are tried in order. With -archive the
+// local file is copied instead.
+func (e *elvui) download() (*os.File, int64, error) {
+	if e.localArchive != "" {
+		return e.copyLocalArchive()
+	}
+	sources := []string{e.downloadURL}
+	for _, mirror := range e.Mirrors {
+		sources = append(sources, mirrorURL(mirror, e.downloadURL))
+	}
+
+	var err error
+	for i, source := range sources {
+		var archive *os.File
+		var size int64
+		if archive, size, err = e.downloadFrom(source); err == nil {
+			if i > 0 {
+				e.infof("%s: downloaded from mirror %s\n", e.Name, downloadHost(source))
 			}
-			return nil
+			return archive, size, nil
+		}
+		if e.context().Err() != nil {
+			break
+		}
+		if i < len(sources)-1 {
+			e.warnf("%s: %v, trying mirror %s\n", e.Name, err, downloadHost(sources[i+1]))
 		}
 	}
+	return nil, 0, err
+}
 
-	return errors.Errorf("local version not found at %s", tocFile)
+// mirrorURL points at the file of downloadURL below the mirror base URL.
+func mirrorURL(base, downloadURL string) string {
+	name := downloadURL
+	if u, err := url.Parse(downloadURL); err == nil {
+		name = u.Path
+	}
+	return strings.TrimSuffix(base, "/") + "/" + path.Base(name)
 }
 
-func (e elvui) downloadAndExtract() error {
-	response, err := http.Get(e.downloadURL)
+func (e *elvui) downloadFrom(source string) (*os.File, int64, error) {
+	ctx, cancel := context.WithTimeout(e.context(), e.DownloadTimeout.or(10*time.Minute))
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, source, nil)
+	if err != nil {
+		return nil, 0, errors.WithStack(err)
+	}
+	response, err := e.do(req)
 	if err != nil {
-		return errors.Wrapf(err, "cannot download file url %s", e.downloadURL)
+		return nil, 0, errors.Wrapf(err, "cannot download file url %s", source)
 	}
 	defer response.Body.Close()
-	// hope tukui don't overflow my memory
-	respBytes, err := ioutil.ReadAll(response.Body)
+	logRedirect(source, response)
+	if response.StatusCode/100 != 2 {
+		return nil, 0, errors.Errorf("%s answers %s", source, response.Status)
+	}
+	body, err := decodedBody(response)
+	if err != nil {
+		return nil, 0, errors.Wrapf(err, "download of %s", source)
+	}
+
+	archive, err := ioutil.TempFile(e.TempDir, "elvuiUpdater-*.zip")
+	if err != nil {
+		return nil, 0, errors.Wrap(err, "cannot create temp file")
+	}
+	sum := md5.New()
+	e.emit(progressEvent{kind: downloadStarted, total: response.ContentLength})
+	progress := &progressWriter{e: e, ev: progressEvent{kind: downloadProgress, total: response.ContentLength}}
+	size, err := io.Copy(io.MultiWriter(archive, sum, progress), body)
+	if err != nil {
+		archive.Close()
+		os.Remove(archive.Name())
+		return nil, 0, errors.Wrap(err, "cannot read response")
+	}
+	if got := hex.EncodeToString(sum.Sum(nil)); e.checksum != "" && got != e.checksum {
+		archive.Close()
+		os.Remove(archive.Name())
+		return nil, 0, errors.Errorf("checksum mismatch for %s: got md5 %s, want %s", source, got, e.checksum)
+	}
+
+	return archive, size, nil
+}
+
+func (e *elvui) downloadAndExtract() error {
+	defer e.emit(progressEvent{kind: progressDone})
+	archive, size, err := e.download()
 	if err != nil {
-		return errors.Wrap(err, "cannot read response")
+		return err
 	}
-	readerBytes := bytes.NewReader(respBytes)
+	defer os.Remove(archive.Name())
+	defer archive.Close()
+	e.downloaded += size
 	// zip work
-	zipReader, err := zip.NewReader(readerBytes, response.ContentLength)
+	zipReader, err := e.openArchive(archive, size)
 	if err != nil {
-		return errors.Wrap(err, "cannot create zip reader")
+		return err
+	}
+	if err := e.checkGroup(topLevelDirs(zipReader)); err != nil {
+		return err
 	}
 
-	// remove older directories
-	for _, dir := range e.Directories {
-		addonDir := filepath.Join(e.addon, dir)
-		if err := os.RemoveAll(addonDir); err != nil {
-			return errors.Wrapf(err, "cannot remove directory %s", addonDir)
-		}
+	// extract everything aside first so a broken archive leaves the install alone
+	staging, err := ioutil.TempDir(e.TempDir, "elvuiUpdater-")
+	if err != nil {
+		return errors.Wrap(err, "cannot create staging directory")
 	}
+	defer os.RemoveAll(staging)
 
+	// the central directory knows every size, so progress has a total upfront
+	progress := &progressWriter{e: e, ev: progressEvent{kind: extractProgress}}
 	for _, f := range zipReader.File {
-		if f.FileInfo().IsDir() {
-			addonDir := filepath.Join(e.addon, f.Name)
-			if err := os.MkdirAll(addonDir, f.Mode()); err != nil {
-				return errors.Wrapf(err, "cannot create directory %s", addonDir)
-			}
-		} else {
-			// open file inside zip for copy
-			fileInZip, err := f.Open()
-			if err != nil {
-				return errors.Wrapf(err, "cannot open file %s inside zip", f.Name)
-			}
-			// create local file
-			localName := filepath.Join(e.addon, f.Name)
-			fileLocal, err := os.Create(localName)
-			if err != nil {
-				return errors.Wrapf(err, "cannot create file %s", localName)
-			}
-			// copy contents over
-			_, err = io.Copy(fileLocal, fileInZip)
-			if err != nil {
-				return errors.Wrapf(err, "cannot extract content from %s to %s", f.Name, localName)
-			}
+		if !f.FileInfo().IsDir() {
+			progress.ev.totalFiles++
+			progress.ev.total += int64(f.UncompressedSize64)
+		}
+	}
+	e.emit(progressEvent{kind: extractStarted, total: progress.ev.total, totalFiles: progress.ev.totalFiles})
+	e.installed = manifest{}
+	for _, f := range zipReader.File {
+		sum, err := e.extractFile(f, staging, progress)
+		if err != nil {
+			return err
+		}
+		if !f.FileInfo().IsDir() {
+			e.installed[f.Name] = sum
+			e.extracted++
+			progress.ev.files++
+			ev := progress.ev
+			ev.kind, ev.file = fileExtracted, f.Name
+			e.emit(ev)
+		}
+	}
+	if e.ManifestURL != "" {
+		if err := e.checkManifest(); err != nil {
+			return err
+		}
+	}
 
-			fileLocal.Close()
-			fileInZip.Close()
+	// replace older directories
+	var old []string
+	for _, dir := range e.cleanupDirectories(topLevelDirs(zipReader)) {
+		addonDir := findFold(e.addon, dir)
+		if !within(e.addon, addonDir) {
+			return errors.Errorf("refusing to remove %s, it is outside %s", addonDir, e.addon)
+		}
+		old = append(old, addonDir)
+	}
+	if err := e.swap(staging, old); err != nil {
+		return err
+	}
+	e.checkDirectories(topLevelDirs(zipReader))
+	if e.KeepArchive {
+		if err := e.keepArchive(archive.Name()); err != nil {
+			e.warnf("%s: %v\n", e.Name, err)
 		}
 	}
 
 	return nil
 }
 
-func main() {
-	quiet := flag.Bool("quiet", false, "don't pause at the end of execution")
-	flag.Parse()
-
-	conf := elvui{localName: "ElvUI", client: &http.Client{Timeout: 5 * time.Second}}
-	if err := conf.init("config.json"); err != nil {
-		log.Fatalf("Fatal: %+v\n", err)
+// keepArchive copies the installed archive into ArchiveDir.
+func (e *elvui) keepArchive(path string) error {
+	dst := e.keptArchivePath()
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return errors.Wrapf(err, "cannot create %s", filepath.Dir(dst))
 	}
+	if err := copyFile(path, dst); err != nil {
+		return errors.Wrapf(err, "cannot keep archive as %s", dst)
+	}
+	e.infof("%s: archive kept as %s\n", e.Name, dst)
+	return nil
+}
 
-	if err := conf.getLocalVersion(); err != nil {
-		log.Fatalf("Fatal: %+v\n", err)
+// keptArchivePath is where keepArchive saves the archive of remoteVersion.
+func (e *elvui) keptArchivePath() string {
+	dir := e.ArchiveDir
+	if dir == "" {
+		dir = filepath.Join(e.StateDir, "archives")
 	}
-	if err := conf.setRemoteVersionNDownloadURL(); err != nil {
-		log.Fatalf("Fatal: %+v\n", err)
+	name := strings.NewReplacer("/", "_", `\`, "_").Replace(e.Name + "-" + e.remoteVersion.String() + ".zip")
+	return filepath.Join(dir, name)
+}
+
+// dryRunReport tells what an update would do besides extracting: the
+// folders replaced with their size, the archive kept, the profiles imported
+// and the game cache cleared.
+func (e *elvui) dryRunReport() {
+	if e.AutoDirectories {
+		e.infof("%s: would replace the folders the archive ships\n", e.Name)
 	}
-	if conf.remoteVersion > conf.localVersion {
-		log.Printf("Upgrading %.2f->%.2f\n", conf.localVersion, conf.remoteVersion)
-		if err := conf.downloadAndExtract(); err != nil {
-			log.Fatalf("Fatal: %+v\n", err)
+	// the archive isn't downloaded, AutoDirectories leaves only the group
+	for _, dir := range e.cleanupDirectories(nil) {
+		path := findFold(e.addon, dir)
+		if size, err := dirSize(path); err == nil {
+			e.infof("%s: would replace %s (%s)\n", e.Name, path, formatBytes(size))
 		}
-		log.Println("Success")
-	} else {
-		log.Println("Nothing to do")
 	}
-
-	if *quiet {
-		return
+	if e.KeepArchive {
+		e.infof("%s: would keep the archive as %s\n", e.Name, e.keptArchivePath())
+	}
+	if e.ImportDefaultProfile && e.localVersion.raw == "" {
+		accounts, _ := wtfAccounts(filepath.Dir(filepath.Dir(e.addon)))
+		e.infof("%s: would import %s into the %d WTF accounts lacking it\n", e.Name, e.DefaultProfile, len(accounts))
+	}
+	if cache := findFold(filepath.Dir(filepath.Dir(e.addon)), "Cache"); e.ClearCache && isDir(cache) {
+		e.infof("%s: would clear %s\n", e.Name, cache)
 	}
+}
 
-	log.Println("Press 'Enter' to finish...")
-	bufio.NewReader(os.Stdin).ReadBytes('\n')
+// dirSize adds up the size of the files below path.
+func dirSize(path string) (int64, error) {
+	var size int64
+	err := filepath.Walk(path, func(_ string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.Mode().IsRegular() {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size, err
+}
+
+// cleanupDirectories returns the folders to wipe before installing, the
+// whole group among them. With AutoDirectories they are archived, the top
+// level folders of the archive.
+func (e *elvui) cleanupDirectories(archived []string) []string {
+	dirs := e.Directories
+	if e.AutoDirectories {
+		dirs = archived
+	}
+	if len(e.Companions) == 0 {
+		return dirs
+	}
+	return append(append([]string(nil), dirs...), difference(e.group(), dirs)...)
+}
+
+// checkDirectories warns when the configured Directories drifted from what
+// the archive actually ships, signalling leftovers or missed cleanup.
+func (e *elvui) checkDirectories(archived []string) {
+	e.verbosef("%s: archive folders %v, configured %v\n", e.Name, archived, e.Directories)
+	if len(e.Directories) == 0 {
+		return
+	}
+
+	missing, extra := difference(e.Directories, archived), difference(archived, e.Directories)
+	if len(missing) > 0 || len(extra) > 0 {
+		e.warnf("%s: configured directories don't match the archive, not in archive %v, not configured %v\n", e.Name, missing, extra)
+	}
+}
+
+// openArchive reads the zip central directory. With FlavorPathPrefix only
+// the entries below it are kept, renamed relative to it, so packages shipping
+// every flavor install like any other. An archive without a single regular
+// file is a bad download, rejected before anything gets wiped. Files at the
+// root of the archive are moved below ExtractInto, which they require.
+// Nightly snapshots keep only the configured Directories. Entries that would
+// land outside the extraction folder reject the whole archive.
+func (e *elvui) openArchive(archive io.ReaderAt, size int64) (*zip.Reader, error) {
+	if size == 0 {
+		return nil, errors.Errorf("archive from %s is empty", e.downloadURL)
+	}
+	r, err := zip.NewReader(archive, size)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot create zip reader")
+	}
+	if prefix := strings.Trim(e.FlavorPathPrefix, "/"); prefix != "" {
+		var kept []*zip.File
+		for _, f := range r.File {
+			if !strings.HasPrefix(f.Name, prefix+"/") || f.Name == prefix+"/" {
+				continue
+			}
+			f.Name = strings.TrimPrefix(f.Name, prefix+"/")
+			kept = append(kept, f)
+		}
+		if len(kept) == 0 {
+			return nil, errors.Errorf("archive has nothing below %s/", prefix)
+		}
+		r.File = kept
+	}
+	if e.channel() == "nightly" {
+		// a repository snapshot also ships docs and tooling at its root
+		var kept []*zip.File
+		for _, f := range r.File {
+			if i := strings.Index(f.Name, "/"); i > 0 && (containsFold(e.Directories, f.Name[:i]) || containsFold(e.Companions, f.Name[:i])) {
+				kept = append(kept, f)
+			}
+		}
+		r.File = kept
+	}
+
+	files, loose := 0, false
+	for _, f := range r.File {
+		if !f.FileInfo().IsDir() {
+			files++
+			loose = loose || !strings.Contains(f.Name, "/")
+		}
+	}
+	if files == 0 {
+		return nil, errors.Errorf("archive from %s has no files, only %d directories", e.downloadURL, len(r.File))
+	}
+	if loose {
+		into := strings.Trim(filepath.ToSlash(e.ExtractInto), "/")
+		if into == "" {
+			return nil, errors.Errorf("archive from %s has files at its root, set ExtractInto to the folder they belong in", e.downloadURL)
+		}
+		for _, f := range r.File {
+			f.Name = into + "/" + f.Name
+		}
+	}
+	for _, f := range r.File {
+		if !entryWithin(f.Name) {
+			return nil, errors.Errorf("archive from %s has %s, outside the folder it extracts into", e.downloadURL, f.Name)
+		}
+	}
+	return r, nil
+}
+
+// entryWithin tells whether the archive entry name stays below the folder it
+// is extracted into: neither absolute nor climbing out with "..".
+func entryWithin(name string) bool {
+	local := filepath.FromSlash(name)
+	if strings.HasPrefix(name, "/") || filepath.IsAbs(local) || filepath.VolumeName(local) != "" {
+		return false
+	}
+	root := filepath.Join(string(filepath.Separator), "root")
+	joined := filepath.Join(root, local)
+	return joined == root || within(root, joined)
+}
+
+// topLevelDirs lists the folders at the root of the archive.
+func topLevelDirs(r *zip.Reader) []string {
+	seen := map[string]bool{}
+	var dirs []string
+	for _, f := range r.File {
+		i := strings.Index(f.Name, "/")
+		if i <= 0 || seen[f.Name[:i]] {
+			continue
+		}
+		seen[f.Name[:i]] = true
+		dirs = append(dirs, f.Name[:i])
+	}
+	sort.Strings(dirs)
+	return dirs
+}
+
+// difference returns the entries of a that are not in b.
+func difference(a, b []string) []string {
+	in := map[string]bool{}
+	for _, s := range b {
+		in[s] = true
+	}
+	var out []string
+	for _, s := range a {
+		if !in[s] {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// extractFile writes a single zip entry below dir, copying its content to
+// progress as well, and returns the SHA-256 of the content, "" for
+// directories. FileMode and DirMode replace the archived permissions.
+func (e *elvui) extractFile(f *zip.File, dir string, progress io.Writer) (string, error) {
+	localName := filepath.Join(dir, f.Name)
+	if f.FileInfo().IsDir() {
+		if err := os.MkdirAll(localName, e.DirMode.or(f.Mode(), 0755)); err != nil {
+			return "", errors.Wrapf(err, "cannot create directory %s", localName)
+		}
+		return "", setMode(localName, e.DirMode)
+	}
+
+	// open file inside zip for copy
+	fileInZip, err := f.Open()
+	if err != nil {
+		return "", errors.Wrapf(err, "cannot open file %s inside zip", f.Name)
+	}
+	defer fileInZip.Close()
+	// create local file, not every archive lists its folders
+	if err := os.MkdirAll(filepath.Dir(localName), e.DirMode.or(0, 0755)); err != nil {
+		return "", errors.Wrapf(err, "cannot create directory %s", filepath.Dir(localName))
+	}
+	if err := setMode(filepath.Dir(localName), e.DirMode); err != nil {
+		return "", err
+	}
+	fileLocal, err := os.OpenFile(localName, os.O_RDWR|os.O_CREATE|os.O_TRUNC, e.FileMode.or(f.Mode(), 0666))
+	if err != nil {
+		return "", errors.Wrapf(err, "cannot create file %s", localName)
+	}
+	defer fileLocal.Close()
+	// copy contents over
+	w, sum := hashingWriter(io.MultiWriter(fileLocal, progress))
+	if _, err = io.Copy(w, fileInZip); err != nil {
+		return "", errors.Wrapf(err, "cannot extract content from %s to %s", f.Name, localName)
+	}
+
+	return sum(), setMode(localName, e.FileMode)
+}
+
+// removeAll is os.RemoveAll that first makes read-only files writable, since
+// Windows refuses to delete them. Clearing the flag is best effort.
+func (e *elvui) removeAll(path string) error {
+	cleared := 0
+	filepath.Walk(path, func(p string, info os.FileInfo, err error) error {
+		if err == nil && info.Mode().IsRegular() && info.Mode().Perm()&0200 == 0 {
+			if os.Chmod(p, info.Mode().Perm()|0200) == nil {
+				cleared++
+			}
+		}
+		return nil
+	})
+	if cleared > 0 {
+		e.infof("%s: cleared the read-only flag of %d files in %s\n", e.Name, cleared, path)
+	}
+	return os.RemoveAll(path)
+}
+
+// move renames src to dst, copying when they live on different volumes.
+func move(src, dst string) error {
+	if err := os.Rename(src, dst); err == nil {
+		return nil
+	}
+
+	err := filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		if info.IsDir() {
+			return os.MkdirAll(target, info.Mode())
+		}
+		return copyFile(path, target)
+	})
+	if err != nil {
+		return errors.Wrapf(err, "cannot move %s to %s", src, dst)
+	}
+
+	return nil
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// stringList is a repeatable string flag.
+type stringList []string
+
+func (l *stringList) String() string { return strings.Join(*l, ",") }
+
+func (l *stringList) Set(v string) error {
+	*l = append(*l, v)
+	return nil
+}
+
+// belowMinVersion warns and reports true when the release is older than
+// MinVersion, the current install is kept then.
+func (e *elvui) belowMinVersion() bool {
+	if e.MinVersion == "" {
+		return false
+	}
+	floor, _ := e.parseVersion(e.MinVersion) // validated by loadConfig
+	if e.remoteVersion.compare(floor) >= 0 {
+		return false
+	}
+	e.warnf("%s: provider offers %s, below MinVersion %s, keeping %s\n", e.Name, e.remoteVersion, floor, e.localVersion)
+	return true
+}
+
+// update installs the latest release when it is newer than the local one.
+// With -force any release that differs from the install goes in, -reinstall
+// also replaces an install that already matches.
+func (e *elvui) update(opts options, st *state) (bool, error) {
+	if err := e.setRemoteVersionNDownloadURL(); err != nil {
+		return false, err
+	}
+	st.addon(e.Name).LastCheck = time.Now()
+	if e.belowMinVersion() {
+		return false, nil
+	}
+	if e.remoteVersion.compare(e.localVersion) <= 0 && !opts.force && !opts.reinstall {
+		if e.remoteVersion.compare(e.localVersion) == 0 && e.remoteVersion.raw != e.localVersion.raw {
+			e.verbosef("%s: %s and %s are the same version\n", e.Name, e.localVersion, e.remoteVersion)
+		}
+		e.infof("%s: Nothing to do\n", e.Name)
+		return false, nil
+	}
+	if !opts.reinstall && e.channel() != "nightly" {
+		// re-read, the TOC is what WoW will actually load
+		if v, err := e.readLocalVersion(); err == nil && v.compare(e.remoteVersion) == 0 {
+			e.localVersion = v
+			e.infof("%s: %s is already installed, use -reinstall to overwrite it\n", e.Name, v)
+			return false, nil
+		}
+	}
+
+	if !e.allowRunning {
+		if err := e.checkGameClosed(); err != nil {
+			return false, err
+		}
+	}
+	if e.PreUpdateHook != "" {
+		if err := e.runHook("pre-update", e.PreUpdateHook); err != nil {
+			e.infof("%s: update aborted, %v\n", e.Name, err)
+			return false, nil
+		}
+	}
+
+	e.infof("Upgrading %s %s->%s from %s\n", e.Name, e.localVersion, e.remoteVersion, downloadHost(e.downloadURL))
+	if err := e.downloadAndExtract(); err != nil {
+		return false, err
+	}
+	st.addon(e.Name).LastUpdate = time.Now()
+	e.infof("%s: %s\n", e.Name, paint(colorGreen, tr("Success")))
+	if err := st.saveManifest(e.Name, e.installed); err != nil {
+		e.warnf("%s: %v\n", e.Name, err)
+	}
+	if e.channel() == "nightly" {
+		st.addon(e.Name).Build = e.remoteVersion.raw
+	} else if v, err := e.readLocalVersion(); err != nil {
+		e.warnf("%s: cannot verify the installed version, %v\n", e.Name, err)
+	} else if v.compare(e.remoteVersion) != 0 {
+		e.warnf("%s: installed TOC reports %s, expected %s\n", e.Name, v, e.remoteVersion)
+	} else {
+		e.checkCompanionVersions(v)
+	}
+	if e.ImportDefaultProfile && e.localVersion.raw == "" {
+		if err := e.importDefaultProfile(); err != nil {
+			e.warnf("%s: %v\n", e.Name, err)
+		}
+	}
+
+	if e.PostUpdateHook != "" {
+		if err := e.runHook("post-update", e.PostUpdateHook); err != nil {
+			if e.FailOnHookError {
+				return true, err
+			}
+			e.warnf("%v\n", err)
+		}
+	}
+
+	return true, nil
+}
+
+// startRun gives the run a fresh root context, ending when Timeout runs out.
+// Call the returned function once the run is over.
+func (c *configuration) startRun() context.CancelFunc {
+	if c.Timeout <= 0 {
+		c.ctx = context.Background()
+		return func() {}
+	}
+	var cancel context.CancelFunc
+	c.ctx, cancel = context.WithTimeout(context.Background(), time.Duration(c.Timeout))
+	return cancel
+}
+
+// context is the root context of the run, ended by Timeout.
+func (c *configuration) context() context.Context {
+	if c.ctx == nil {
+		return context.Background()
+	}
+	return c.ctx
+}
+
+// downloadHost shortens a download URL to its host for the log, signed
+// query strings are long and not meant to be shared.
+func downloadHost(raw string) string {
+	if u, err := url.Parse(raw); err == nil && u.Host != "" {
+		return u.Host
+	}
+	return raw
+}
+
+// exit codes, see run
+const (
+	exitOK              = 0
+	exitPartialFailure  = 1
+	exitTotalFailure    = 2
+	exitTimeout         = 3
+	exitUpdateAvailable = 10
+)
+
+// options are the command line switches shaping what happens to each addon.
+type options struct {
+	status bool
+	diff   bool
+	check  bool
+	verify bool
+	repair bool
+	// dryRun tells what an update would do, probe also asks the download URL
+	dryRun bool
+	probe  bool
+	// listRemote prints what the providers offer, json as JSON on stdout
+	listRemote bool
+	json       bool
+	force      bool
+	reinstall  bool
+}
+
+// readOnly reports whether opts only look, leaving installs and state alone.
+func (o options) readOnly() bool {
+	return o.status || o.diff || o.verify || o.listRemote || o.dryRun
+}
+
+// process runs the requested operation for one addon and reports whether it
+// got updated.
+func (e *elvui) process(opts options, st *state) (bool, error) {
+	if opts.listRemote {
+		if err := e.setRemoteVersionNDownloadURL(); err != nil {
+			return false, err
+		}
+		if !opts.json {
+			e.infof("%s %s %s\n", e.Name, e.remoteVersion, e.downloadURL)
+		}
+		return false, nil
+	}
+	if err := e.getLocalVersion(); err != nil {
+		return false, err
+	}
+	if e.channel() == "nightly" {
+		e.localVersion, _ = parseVersionScheme(st.addon(e.Name).Build, "numeric")
+	}
+
+	switch {
+	case opts.status:
+		s := st.addon(e.Name)
+		e.infof("%s %s, last check %s, last update %s\n", e.Name, e.localVersion, formatTime(s.LastCheck), formatTime(s.LastUpdate))
+		return false, nil
+	case opts.diff:
+		if err := e.setRemoteVersionNDownloadURL(); err != nil {
+			return false, err
+		}
+		return false, e.diff()
+	case opts.verify:
+		return false, e.verify(st)
+	case opts.repair:
+		return e.repair(opts, st)
+	case opts.dryRun:
+		if err := e.setRemoteVersionNDownloadURL(); err != nil {
+			return false, err
+		}
+		if e.remoteVersion.compare(e.localVersion) > 0 || opts.force || opts.reinstall {
+			e.infof("%s: would upgrade %s->%s from %s\n", e.Name, e.localVersion, e.remoteVersion, e.downloadURL)
+			e.dryRunReport()
+		} else {
+			e.infof("%s: %s is up to date\n", e.Name, e.localVersion)
+		}
+		if opts.probe {
+			return false, e.probe()
+		}
+		return false, nil
+	case opts.check:
+		if err := e.setRemoteVersionNDownloadURL(); err != nil {
+			return false, err
+		}
+		st.addon(e.Name).LastCheck = time.Now()
+		if e.belowMinVersion() {
+			return false, nil
+		}
+		if e.remoteVersion.compare(e.localVersion) > 0 {
+			e.updateAvailable = true
+			e.infof("%s: update available %s->%s\n", e.Name, e.localVersion, e.remoteVersion)
+		} else {
+			e.infof("%s: up to date\n", e.Name)
+		}
+		return false, nil
+	}
+
+	return e.update(opts, st)
+}
+
+func main() {
+	os.Exit(run())
+}
+
+// run processes every selected addon, carrying on past failures, and returns
+// the exit code: 0 all went fine, 1 some addons failed, 2 all failed or the
+// setup did, 3 the run exceeded Timeout, 10 updates are available (-check
+// only).
+func run() int {
+	var only, skip stringList
+	var opts options
+	quiet := flag.Bool("quiet", false, "don't pause at the end of execution nor print the summary of downloads")
+	warnOnly := flag.Bool("q", false, "log only warnings and errors, implies -quiet")
+	errorsOnly := flag.Bool("qq", false, "log only errors, implies -quiet")
+	var silent bool
+	flag.BoolVar(&silent, "s", false, "log nothing, only the exit code tells how it went, implies -quiet")
+	flag.BoolVar(&silent, "silent", false, "same as -s")
+	flag.BoolVar(&verbose, "verbose", false, "log extra diagnostics")
+	groupOutput := flag.Bool("group-output", false, "hold each addon's output back and print it grouped by addon, in config order")
+	noColor := flag.Bool("no-color", false, "never color the output, same as setting NO_COLOR")
+	metricsFile := flag.String("metrics-file", "", "write a node_exporter textfile collector file after each run")
+	printConfig := flag.Bool("print-config", false, "print the effective config as JSON, credentials redacted, then exit")
+	configTest := flag.Bool("config-test", false, "validate the config and the installed TOC files without any request or change, then exit")
+	flag.BoolVar(&opts.status, "status", false, "show installed version and last check/update times, then exit")
+	flag.BoolVar(&opts.diff, "diff", false, "download the latest archives and list the files an update would add, modify or remove, then exit")
+	flag.BoolVar(&opts.check, "check", false, "only report available updates, exit code 10 when there are some")
+	flag.BoolVar(&opts.verify, "verify", false, "check the installed files against the manifest of the last install, then exit")
+	flag.BoolVar(&opts.listRemote, "list-remote", false, "print the latest version and download URL each provider offers, then exit")
+	flag.BoolVar(&opts.json, "json", false, "print -list-remote output as JSON")
+	flag.BoolVar(&opts.repair, "repair", false, "re-extract the files of the last install that are missing or modified, reinstall when that isn't possible")
+	pingProviders := flag.Bool("ping", false, "tell whether each provider API answers and how fast, without resolving releases, then exit")
+	flag.BoolVar(&opts.dryRun, "dry-run", false, "resolve every release and tell what an update would do, changing nothing")
+	flag.BoolVar(&opts.probe, "probe", false, "with -dry-run, also check that each download URL answers, without downloading it")
+	flag.BoolVar(&opts.force, "force", false, "install the provider's release even when it isn't newer, unless it is already installed")
+	forceCheckAll := flag.Bool("force-check-all", false, "ask every provider afresh, ignoring -since and cached answers; unlike -force it installs only what is newer")
+	flag.BoolVar(&opts.reinstall, "reinstall", false, "reinstall even when the installed version matches the release")
+	wantVersion := flag.String("version", "", "install this release instead of the latest, for the single addon picked with -only")
+	outputDir := flag.String("output-dir", "", "install into this folder instead of the game's AddOns, keeping its own state inside it")
+	localArchive := flag.String("archive", "", "install this zip file instead of downloading, for the single addon picked with -only")
+	keepArchive := flag.Bool("keep-archive", false, "save each installed archive, same as KeepArchive")
+	concurrency := flag.Int("concurrency", 0, "process up to this many addons at once, same as Concurrency (default 4)")
+	notifyOn := flag.String("notify-on", "", "report a run only when it changed something or when it failed: changed, error or always, same as NotifyOn")
+	clearCache := flag.Bool("clear-cache", false, "remove the game's Cache folder after an update, same as ClearCache")
+	allowRunning := flag.Bool("allow-running", false, "update even while the game is running")
+	waitForClose := flag.Duration("wait-for-close", 0, "when the game is running, wait up to this long for it to exit before updating, e.g. 2h")
+	install := flag.String("install", "", "game folder to update when several are installed, e.g. _classic_, or its path")
+	listInstalls := flag.Bool("list-installs", false, "print the WoW installs found through the registry, then exit")
+	portable := flag.Bool("portable", false, "look for WoW next to the executable before using the registry")
+	flag.Var(&only, "only", "update only the named addon, repeatable")
+	profile := flag.String("profile", "", "update only the addons of the named profile, see Profiles")
+	watch := flag.Duration("watch", 0, "keep running and check again after this long, e.g. 6h")
+	jitter := flag.Float64("jitter", 0.1, "with -watch, vary each wait randomly by up to this fraction of it")
+	trayMode := flag.Bool("tray", false, "run in the Windows notification area, checking every -watch (default 6h) and updating from its menu")
+	resume := flag.Bool("resume", false, "skip the addons the last run got through before it failed or was interrupted")
+	since := flag.Duration("since", 0, "leave out addons checked less than this long ago, e.g. 6h")
+	flag.Var(&skip, "skip", "leave the named addon out of this run, repeatable")
+	loginProvider := flag.String("login", "", "store a credential for the provider in the OS keychain, then exit")
+	configPath := flag.String("config", "", "config file, - reads it from stdin (default config.json, config.yaml or config.yml)")
+	timeout := flag.Duration("timeout", 0, "give up on the whole run after this long, e.g. 15m, overrides Timeout")
+	lang := flag.String("lang", "", "language of the messages, e.g. de or pt_BR (default from LANG)")
+	flag.Parse()
+	switch {
+	case silent:
+		level = levelSilent
+	case *errorsOnly:
+		level = levelError
+	case *warnOnly:
+		level = levelWarn
+	}
+	if level > levelInfo {
+		*quiet = true
+	}
+	setupColor(*noColor)
+	if err := setupLocale(*lang); err != nil {
+		warnf("%v\n", err)
+	}
+	if *configPath == "" {
+		*configPath = findConfig()
+	}
+
+	if *loginProvider != "" {
+		if err := login(*loginProvider); err != nil {
+			fatalf("%+v\n", err)
+			return exitTotalFailure
+		}
+		infof("Stored %s credential\n", *loginProvider)
+		return exitOK
+	}
+
+	if *listInstalls {
+		// the registry settings of the config, as a run would look them up
+		c, err := readConfig(*configPath)
+		if err != nil {
+			fatalf("%+v\n", err)
+			return exitTotalFailure
+		}
+		installs, err := discoverInstalls(c.RegistryHive, c.RegistryKeyPath)
+		if err != nil {
+			fatalf("%+v\n", err)
+			return exitTotalFailure
+		}
+		for _, dir := range installs {
+			fmt.Println(dir)
+		}
+		return exitOK
+	}
+
+	if *outputDir != "" {
+		abs, err := filepath.Abs(*outputDir)
+		if err != nil {
+			fatalf("%+v\n", errors.WithStack(err))
+			return exitTotalFailure
+		}
+		*outputDir = abs
+	}
+	conf, err := loadConfig(*configPath, *portable, *install, *outputDir)
+	if err == nil && *profile != "" {
+		var members []string
+		if members, err = conf.profile(*profile); err == nil {
+			only = append(only, members...)
+		}
+	}
+	if *configTest {
+		if err != nil {
+			errorf("Config test failed: %v\n", err)
+			return exitTotalFailure
+		}
+		addons, _, _, err := conf.selectAddons(only, skip)
+		if err != nil {
+			errorf("Config test failed: %v\n", err)
+			return exitTotalFailure
+		}
+		problems := conf.configTest(addons)
+		if len(problems) > 0 {
+			for _, p := range problems {
+				errorf("%s\n", p)
+			}
+			errorf("Config test failed, %d problems\n", len(problems))
+			return exitTotalFailure
+		}
+		infof("Config test OK\n")
+		return exitOK
+	}
+	if err != nil {
+		fatalf("%+v\n", err)
+		return exitTotalFailure
+	}
+	conf.allowRunning = *allowRunning
+	conf.waitForClose = *waitForClose
+	conf.forceCheck = *forceCheckAll
+	if *concurrency != 0 {
+		conf.Concurrency = *concurrency
+		conf.setSource("Concurrency", "flag -concurrency")
+	}
+	if *notifyOn != "" {
+		if conf.NotifyOn, err = normalizeNotifyOn(*notifyOn); err != nil {
+			fatalf("%+v\n", err)
+			return exitTotalFailure
+		}
+		conf.setSource("NotifyOn", "flag -notify-on")
+	}
+	if *clearCache {
+		conf.ClearCache = true
+		conf.setSource("ClearCache", "flag -clear-cache")
+	}
+	if *keepArchive {
+		conf.KeepArchive = true
+		conf.setSource("KeepArchive", "flag -keep-archive")
+	}
+	if *timeout > 0 {
+		conf.Timeout = duration(*timeout)
+		conf.setSource("Timeout", "flag -timeout")
+	}
+	if *printConfig {
+		if err := conf.printConfig(); err != nil {
+			fatalf("%+v\n", err)
+			return exitTotalFailure
+		}
+		return exitOK
+	}
+	addons, skipped, disabled, err := conf.selectAddons(only, skip)
+	if err != nil {
+		fatalf("%+v\n", err)
+		return exitTotalFailure
+	}
+	if *pingProviders {
+		return ping(conf, conf.newClient(), addons)
+	}
+	if *outputDir != "" {
+		// nothing of a game is around, let alone running
+		if err := os.MkdirAll(conf.addon, 0755); err != nil {
+			fatalf("%+v\n", errors.WithStack(err))
+			return exitTotalFailure
+		}
+		conf.allowRunning, conf.ClearCache = true, false
+		for i := range addons {
+			addons[i].ImportDefaultProfile = false
+		}
+		conf.StateDir = filepath.Join(conf.addon, ".elvuiUpdater")
+	} else if err := ensureAddOns(conf.addon); err != nil {
+		fatalf("%+v\n", err)
+		return exitTotalFailure
+	}
+	if *localArchive != "" {
+		if len(addons) != 1 {
+			fatalf("-archive installs a single addon, pick it with -only\n")
+			return exitTotalFailure
+		}
+		if conf.localArchive, err = filepath.Abs(*localArchive); err != nil {
+			fatalf("%+v\n", errors.WithStack(err))
+			return exitTotalFailure
+		}
+		// whatever its version, the archive was asked for
+		opts.force = true
+	}
+	if *wantVersion != "" {
+		if len(addons) != 1 || *localArchive != "" {
+			fatalf("-version installs a single addon, pick it with -only and leave out -archive\n")
+			return exitTotalFailure
+		}
+		conf.wantVersion = *wantVersion
+		// older than what is installed is the point of it
+		opts.force = true
+	}
+	if conf.StateDir == "" {
+		if conf.StateDir, err = defaultStateDir(); err != nil {
+			fatalf("%+v\n", err)
+			return exitTotalFailure
+		}
+	}
+	legacyDir := filepath.Dir(*configPath)
+	if *outputDir != "" {
+		// the state of the live install stays where it is
+		legacyDir = conf.StateDir
+	}
+	st, err := openState(conf.StateDir, legacyDir)
+	if err != nil {
+		fatalf("%+v\n", err)
+		return exitTotalFailure
+	}
+	b := &batch{
+		conf:        conf,
+		client:      conf.newClient(),
+		st:          st,
+		addons:      addons,
+		skipped:     skipped,
+		disabled:    disabled,
+		resume:      *resume,
+		opts:        opts,
+		since:       *since,
+		groupOutput: *groupOutput,
+		quiet:       *quiet || *watch > 0 || *trayMode,
+		metricsFile: *metricsFile,
+	}
+	if *watch <= 0 && !*trayMode {
+		return b.run()
+	}
+	if *jitter < 0 || *jitter > 1 {
+		fatalf("-jitter must be between 0 and 1\n")
+		return exitTotalFailure
+	}
+	if *trayMode {
+		return b.runTray(*watch, *jitter)
+	}
+	for {
+		b.run()
+		wait := jittered(*watch, *jitter)
+		next := infof
+		if conf.NotifyOn != "always" {
+			next = verbosef
+		}
+		next("Next check at %s\n", time.Now().Add(wait).Format("2006-01-02 15:04:05"))
+		time.Sleep(wait)
+	}
+}
+
+// batch is one pass over the selected addons, -watch repeats it.
+type batch struct {
+	conf        *configuration
+	client      *http.Client
+	st          *state
+	addons      []addonConfig
+	skipped     []string
+	disabled    []string
+	resume      bool
+	opts        options
+	since       time.Duration
+	groupOutput bool
+	quiet       bool
+	metricsFile string
+	// last are the addons of the latest run, for -tray
+	last []*elvui
+}
+
+// maxConcurrency caps Concurrency, more would only add load on the APIs and
+// CDNs.
+const maxConcurrency = 16
+
+// concurrency is Concurrency clamped between 1 and maxConcurrency, 4 when
+// unset.
+func (c *configuration) concurrency() int {
+	switch n := c.Concurrency; {
+	case n == 0:
+		return 4
+	case n < 1:
+		return 1
+	case n > maxConcurrency:
+		return maxConcurrency
+	default:
+		return n
+	}
+}
+
+// run processes every addon once and returns the exit code.
+func (b *batch) run() int {
+	start := time.Now()
+	conf, st, opts := b.conf, b.st, b.opts
+	skipped := append([]string(nil), b.skipped...)
+	conf.responses.reset()
+	defer conf.startRun()()
+
+	// the checkpoint lets -resume skip what an interrupted run already did
+	checkpoint := !opts.readOnly() && !opts.check
+	// the other read only modes have their own output
+	statusLines := !opts.readOnly() || opts.check
+	if checkpoint {
+		conf.sweepAside()
+	}
+	var done []string
+	if checkpoint && b.resume {
+		var err error
+		if done, err = st.loadCheckpoint(); err != nil {
+			warnf("%+v\n", err)
+		}
+	}
+
+	// up to Concurrency addons are processed at once, the checkpoint is
+	// sorted and the results are kept in config order all the same
+	order := map[string]int{}
+	for i, a := range b.addons {
+		order[a.Name] = i
+	}
+	var updaters []*elvui
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, conf.concurrency())
+	// the progress bar needs the terminal to itself, one addon at a time
+	progress := cap(slots) == 1 || len(b.addons) == 1
+	resumed := append([]string(nil), done...)
+	for _, a := range b.addons {
+		if containsFold(resumed, a.Name) {
+			verbosef("%s: done by the interrupted run\n", a.Name)
+			skipped = append(skipped, a.Name)
+			continue
+		}
+		if s := st.addon(a.Name); b.since > 0 && !conf.forceCheck && !s.Failed && time.Since(s.LastCheck) < b.since {
+			verbosef("%s: checked %s, less than %s ago\n", a.Name, formatTime(s.LastCheck), b.since)
+			skipped = append(skipped, a.Name)
+			continue
+		}
+		slots <- struct{}{}
+		if conf.ctx.Err() != nil {
+			break
+		}
+		e := &elvui{configuration: conf, addonConfig: a, client: b.client}
+		e.log = log.New(os.Stderr, "", log.LstdFlags)
+		// held back until it is known whether the addon is worth reporting
+		if b.groupOutput || conf.NotifyOn != "always" {
+			e.log.SetOutput(&e.output)
+		} else if interactive && !b.quiet && progress {
+			e.progress = progressBar()
+		}
+		updaters = append(updaters, e)
+
+		wg.Add(1)
+		go func() {
+			defer func() { <-slots; wg.Done() }()
+			addonStart := time.Now()
+			e.updated, e.err = e.process(opts, st)
+			e.elapsed = time.Since(addonStart)
+			if e.err != nil {
+				e.errorf("%s: %+v\n", e.Name, e.err)
+			}
+			if statusLines {
+				e.infof("STATUS: %s %s\n", e.status(), e.Name)
+				st.addon(e.Name).Failed = e.err != nil
+			}
+			if e.err != nil {
+				return
+			}
+			if checkpoint {
+				mu.Lock()
+				defer mu.Unlock()
+				done = append(done, e.Name)
+				sort.SliceStable(done, func(i, j int) bool { return order[done[i]] < order[done[j]] })
+				if err := st.saveCheckpoint(done); err != nil {
+					warnf("%+v\n", err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	b.last = updaters
+
+	var metrics []addonMetric
+	updates, failures, available := 0, 0, 0
+	for _, e := range updaters {
+		if e.err != nil {
+			failures++
+			metrics = append(metrics, addonMetric{name: e.Name, failed: true})
+			continue
+		}
+		if e.updateAvailable {
+			available++
+		}
+		installed := e.localVersion
+		if e.updated {
+			installed = e.remoteVersion
+			updates++
+		}
+		metrics = append(metrics, addonMetric{name: e.Name, installed: installed.float(), latest: e.remoteVersion.float()})
+	}
+
+	reported := false
+	for _, e := range updaters {
+		if e.noteworthy(conf.NotifyOn) {
+			reported = true
+			os.Stderr.Write(e.output.Bytes())
+		}
+	}
+
+	timedOut := conf.ctx.Err() == context.DeadlineExceeded
+	if timedOut {
+		errorf("the run exceeded its %s timeout, %d of %d addons were processed\n", time.Duration(conf.Timeout), len(updaters), len(b.addons))
+	}
+
+	code := exitOK
+	switch {
+	case timedOut:
+		code = exitTimeout
+	case failures > 0 && failures == len(updaters):
+		code = exitTotalFailure
+	case failures > 0:
+		code = exitPartialFailure
+	case opts.check && available > 0:
+		code = exitUpdateAvailable
+	}
+	if opts.listRemote && opts.json {
+		if err := printRemote(updaters); err != nil {
+			errorf("%+v\n", err)
+		}
+	}
+	reported = reported || timedOut || conf.NotifyOn == "always"
+	if statusLines && reported {
+		if conf.NotifyOn == "always" {
+			// never processed, unlike the skipped status of status()
+			for _, name := range skipped {
+				infof("STATUS: excluded %s\n", name)
+			}
+			for _, name := range b.disabled {
+				infof("STATUS: disabled %s\n", name)
+			}
+		}
+		infof("STATUS: exit %d %s\n", code, exitStatus(code))
+	}
+	if reported && conf.NotifyCommand != "" {
+		if err := conf.notify(updaters, code); err != nil {
+			warnf("%+v\n", err)
+		}
+	}
+	if opts.readOnly() {
+		return code
+	}
+	if checkpoint && failures == 0 && !timedOut {
+		if err := st.clearCheckpoint(); err != nil {
+			warnf("%+v\n", err)
+		}
+	}
+
+	if err := st.save(); err != nil {
+		warnf("cannot save state: %+v\n", err)
+	}
+
+	if updates > 0 && conf.ClearCache {
+		if err := clearGameCache(filepath.Dir(filepath.Dir(conf.addon))); err != nil {
+			warnf("%+v\n", err)
+		}
+	}
+
+	if b.metricsFile != "" {
+		if err := writeMetrics(b.metricsFile, metrics, updates, failures == 0 && !timedOut); err != nil {
+			warnf("cannot write metrics: %+v\n", err)
+		}
+	}
+
+	if b.quiet || !reported {
+		return code
+	}
+
+	var downloaded int64
+	var extracted int
+	for _, e := range updaters {
+		if len(updaters) > 1 {
+			result := fmt.Sprintf(tr("downloaded %s, extracted %d files"), formatBytes(e.downloaded), e.extracted)
+			if e.err != nil {
+				result = tr("failed")
+			}
+			infof("%s: %s in %s\n", e.Name, result, e.elapsed.Round(time.Millisecond))
+		}
+		downloaded += e.downloaded
+		extracted += e.extracted
+	}
+	for _, name := range skipped {
+		infof("%s: skipped\n", name)
+	}
+	for _, name := range b.disabled {
+		infof("%s: disabled\n", name)
+	}
+	infof("Downloaded %s, extracted %d files in %s\n", formatBytes(downloaded), extracted, time.Since(start).Round(time.Millisecond))
+
+	infof("Press 'Enter' to finish...\n")
+	bufio.NewReader(os.Stdin).ReadBytes('\n')
+
+	return code
+}
+
+// printConfig writes the effective configuration as JSON: every addon merged
+// with Defaults and normalized in Addons, the resolved AddOns folder and the
+// credentials redacted.
+func (c *configuration) printConfig() error {
+	raw, err := json.Marshal(c)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	var out map[string]interface{}
+	if err := json.Unmarshal(raw, &out); err != nil {
+		return errors.WithStack(err)
+	}
+	t := reflect.TypeOf(addonConfig{})
+	for i := 0; i < t.NumField(); i++ {
+		delete(out, t.Field(i).Name)
+	}
+	delete(out, "Defaults")
+
+	addons := c.addons()
+	for i := range addons {
+		a := &addons[i]
+		if a.Provider == "" {
+			a.Provider = "tukui"
+		}
+		a.Provider = strings.ToLower(a.Provider)
+		a.Flavor, _ = normalizeFlavor(a.Flavor)
+		a.Channel, _ = normalizeChannel(a.Channel)
+		if a.Enabled == nil {
+			enabled := true
+			a.Enabled = &enabled
+		}
+		if len(a.Headers) > 0 {
+			redacted := make(map[string]string, len(a.Headers))
+			for name := range a.Headers {
+				redacted[name] = "REDACTED"
+			}
+			a.Headers = redacted
+		}
+		// $VAR expansion may have put an API key in them
+		a.Page, a.ManifestURL = redactURL(a.Page), redactURL(a.ManifestURL)
+		if len(a.Mirrors) > 0 {
+			mirrors := make([]string, len(a.Mirrors))
+			for i, mirror := range a.Mirrors {
+				mirrors[i] = redactURL(mirror)
+			}
+			a.Mirrors = mirrors
+		}
+	}
+	out["Addons"] = addons
+	out["AddOns"] = c.addon
+	if c.StateDir == "" {
+		out["StateDir"], _ = defaultStateDir()
+	}
+	for _, key := range []string{"GitHubToken", "CurseForgeAPIKey", "WagoAPIKey"} {
+		if out[key] != "" {
+			out[key] = "REDACTED"
+		}
+	}
+	if u, err := url.Parse(c.Proxy); err == nil && c.Proxy != "" {
+		out["Proxy"] = u.Redacted()
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(out); err != nil {
+		return errors.WithStack(err)
+	}
+	if verbose {
+		c.printSources(out, addons)
+	}
+	return nil
+}
+
+// redactURL hides the userinfo and query values of raw, where credentials
+// usually go in a URL.
+func redactURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil || (u.User == nil && u.RawQuery == "") {
+		return raw
+	}
+	if u.User != nil {
+		u.User = url.User("REDACTED")
+	}
+	if u.RawQuery != "" {
+		query := u.Query()
+		for key := range query {
+			query[key] = []string{"REDACTED"}
+		}
+		u.RawQuery = query.Encode()
+	}
+	return u.String()
+}
+
+// printRemote writes the -list-remote results of every addon as a JSON array.
+func printRemote(updaters []*elvui) error {
+	type remote struct {
+		Name    string `json:"name"`
+		Version string `json:"version,omitempty"`
+		URL     string `json:"url,omitempty"`
+		Error   string `json:"error,omitempty"`
+	}
+	list := make([]remote, 0, len(updaters))
+	for _, e := range updaters {
+		r := remote{Name: e.Name}
+		if e.err != nil {
+			r.Error = e.err.Error()
+		} else {
+			r.Version, r.URL = e.remoteVersion.raw, e.downloadURL
+		}
+		list = append(list, r)
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return errors.WithStack(enc.Encode(list))
+}
+
+var jitterSource = rand.New(rand.NewSource(time.Now().UnixNano()))
+
+// jittered varies interval randomly by up to ±fraction of it, so updaters
+// started at the same minute drift apart.
+func jittered(interval time.Duration, fraction float64) time.Duration {
+	spread := float64(interval) * fraction
+	return interval + time.Duration((jitterSource.Float64()*2-1)*spread)
+}
+
+// formatBytes renders n using binary units, e.g. 1.5 MiB.
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
 }
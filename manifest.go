@@ -0,0 +1,127 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// manifest maps slash separated paths, relative to AddOns, to the SHA-256 of
+// each file.
+type manifest map[string]string
+
+// compare lists the paths of m missing from got, the paths of got that m
+// doesn't know and the paths whose checksum differs.
+func (m manifest) compare(got manifest) (missing, extra, modified []string) {
+	for path, sum := range m {
+		if gotSum, ok := got[path]; !ok {
+			missing = append(missing, path)
+		} else if gotSum != sum {
+			modified = append(modified, path)
+		}
+	}
+	for path := range got {
+		if _, ok := m[path]; !ok {
+			extra = append(extra, path)
+		}
+	}
+	sort.Strings(missing)
+	sort.Strings(extra)
+	sort.Strings(modified)
+	return missing, extra, modified
+}
+
+// fetchManifest downloads the sidecar manifest at ManifestURL.
+func (e *elvui) fetchManifest() (manifest, error) {
+	m := manifest{}
+	if err := e.getJSON(e.ManifestURL, nil, &m); err != nil {
+		return nil, errors.Wrapf(err, "cannot fetch manifest %s", e.ManifestURL)
+	}
+	return m, nil
+}
+
+// checkManifest verifies the extracted files against the provider manifest.
+func (e *elvui) checkManifest() error {
+	expected, err := e.fetchManifest()
+	if err != nil {
+		return err
+	}
+	missing, extra, modified := expected.compare(e.installed)
+	var problems []string
+	for _, group := range []struct {
+		what  string
+		paths []string
+	}{{"missing", missing}, {"unexpected", extra}, {"modified", modified}} {
+		if len(group.paths) > 0 {
+			problems = append(problems, group.what+" "+strings.Join(group.paths, ", "))
+		}
+	}
+	if len(problems) > 0 {
+		return errors.Errorf("archive doesn't match manifest %s: %s", e.ManifestURL, strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// manifestPath is where the manifest of the last install of an addon lives.
+func (s *state) manifestPath(name string) string {
+	return filepath.Join(filepath.Dir(s.path), "manifests", name+".json")
+}
+
+func (s *state) saveManifest(name string, m manifest) error {
+	path := s.manifestPath(name)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return errors.Wrapf(err, "cannot create %s", filepath.Dir(path))
+	}
+	raw, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "cannot marshal manifest")
+	}
+	if err := ioutil.WriteFile(path, raw, 0644); err != nil {
+		return errors.Wrapf(err, "cannot write manifest %s", path)
+	}
+	return nil
+}
+
+// loadManifest reads the manifest saved by the last install of an addon.
+func (s *state) loadManifest(name string) (manifest, error) {
+	path := s.manifestPath(name)
+	raw, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, errors.Errorf("no manifest recorded for %s, update it once first", name)
+	} else if err != nil {
+		return nil, errors.Wrapf(err, "cannot read manifest %s", path)
+	}
+	m := manifest{}
+	if err := json.Unmarshal(raw, &m); err != nil {
+		return nil, errors.Wrapf(err, "cannot parse manifest %s", path)
+	}
+	return m, nil
+}
+
+// hashFile returns the SHA-256 of the file at path.
+func hashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	w, sum := hashingWriter(ioutil.Discard)
+	if _, err := io.Copy(w, f); err != nil {
+		return "", errors.Wrapf(err, "cannot read %s", path)
+	}
+	return sum(), nil
+}
+
+// hashingWriter wraps w so every byte written also feeds a SHA-256.
+func hashingWriter(w io.Writer) (io.Writer, func() string) {
+	h := sha256.New()
+	return io.MultiWriter(w, h), func() string { return hex.EncodeToString(h.Sum(nil)) }
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	updatesAppliedMetric = "elvuiupdater_updates_applied_total"
+	lastSuccessMetric    = "elvuiupdater_last_success_timestamp_seconds"
+)
+
+// addonMetric holds the per addon gauges of a run. A failed addon keeps the
+// values of the previous file.
+type addonMetric struct {
+	name      string
+	installed float64
+	latest    float64
+	failed    bool
+}
+
+// writeMetrics dumps a node_exporter textfile collector file. The updates
+// counter is carried over from the previous file so it keeps increasing, and
+// so is the last success time unless success tells this run went fine.
+func writeMetrics(path string, addons []addonMetric, updates int, success bool) error {
+	applied := previousCounter(path, updatesAppliedMetric) + float64(updates)
+	lastSuccess := previousCounter(path, lastSuccessMetric)
+	if success {
+		lastSuccess = float64(time.Now().Unix())
+	}
+
+	var b strings.Builder
+	gauge := func(metric string, value func(addonMetric) float64) {
+		for _, a := range addons {
+			series := fmt.Sprintf("%s{addon=%q}", metric, a.name)
+			v := value(a)
+			if a.failed {
+				var ok bool
+				if v, ok = previousValue(path, series); !ok {
+					continue
+				}
+			}
+			fmt.Fprintf(&b, "%s %g\n", series, v)
+		}
+	}
+	fmt.Fprintln(&b, "# HELP elvuiupdater_installed_version Installed addon version.")
+	fmt.Fprintln(&b, "# TYPE elvuiupdater_installed_version gauge")
+	gauge("elvuiupdater_installed_version", func(a addonMetric) float64 { return a.installed })
+	fmt.Fprintln(&b, "# HELP elvuiupdater_latest_version Latest addon version offered by the provider.")
+	fmt.Fprintln(&b, "# TYPE elvuiupdater_latest_version gauge")
+	gauge("elvuiupdater_latest_version", func(a addonMetric) float64 { return a.latest })
+	fmt.Fprintln(&b, "# HELP "+updatesAppliedMetric+" Updates applied since the metrics file was created.")
+	fmt.Fprintln(&b, "# TYPE "+updatesAppliedMetric+" counter")
+	fmt.Fprintf(&b, "%s %g\n", updatesAppliedMetric, applied)
+	fmt.Fprintln(&b, "# HELP "+lastSuccessMetric+" Time of the last successful check.")
+	fmt.Fprintln(&b, "# TYPE "+lastSuccessMetric+" gauge")
+	fmt.Fprintf(&b, "%s %d\n", lastSuccessMetric, int64(lastSuccess))
+
+	// the collector may read at any time, never let it see a partial file
+	tmp, err := ioutil.TempFile(filepath.Dir(path), ".metrics-")
+	if err != nil {
+		return errors.Wrap(err, "cannot create metrics file")
+	}
+	if _, err := tmp.WriteString(b.String()); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return errors.Wrap(err, "cannot write metrics file")
+	}
+	tmp.Close()
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return errors.Wrapf(err, "cannot replace metrics file %s", path)
+	}
+
+	return nil
+}
+
+// previousCounter reads metric back from an earlier metrics file, 0 if absent.
+func previousCounter(path, metric string) float64 {
+	v, _ := previousValue(path, metric)
+	return v
+}
+
+// previousValue reads series, a metric name with its labels, back from an
+// earlier metrics file.
+func previousValue(path, series string) (float64, bool) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, false
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		// label values may hold spaces, the value is what follows the last
+		line := scanner.Text()
+		if i := strings.LastIndexByte(line, ' '); i > 0 && line[:i] == series {
+			v, err := strconv.ParseFloat(line[i+1:], 64)
+			return v, err == nil
+		}
+	}
+
+	return 0, false
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWriteMetrics(t *testing.T) {
+	installed := `elvuiupdater_installed_version{addon="Elv UI"}`
+	latest := `elvuiupdater_latest_version{addon="Elv UI"}`
+	previous := installed + " 13.05\n" + latest + " 13.06\n" +
+		updatesAppliedMetric + " 3\n" + lastSuccessMetric + " 1000\n"
+
+	tests := []struct {
+		name    string
+		addons  []addonMetric
+		updates int
+		success bool
+		want    map[string]float64
+		missing []string
+	}{
+		{
+			name:    "success",
+			addons:  []addonMetric{{name: "Elv UI", installed: 13.06, latest: 13.06}},
+			updates: 1,
+			success: true,
+			want:    map[string]float64{installed: 13.06, latest: 13.06, updatesAppliedMetric: 4},
+		},
+		{
+			name:    "failure keeps the last known values",
+			addons:  []addonMetric{{name: "Elv UI", failed: true}, {name: "New", failed: true}},
+			success: false,
+			want:    map[string]float64{installed: 13.05, latest: 13.06, updatesAppliedMetric: 3, lastSuccessMetric: 1000},
+			missing: []string{`elvuiupdater_installed_version{addon="New"}`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "elvuiupdater.prom")
+			if err := ioutil.WriteFile(path, []byte(previous), 0644); err != nil {
+				t.Fatal(err)
+			}
+			if err := writeMetrics(path, tt.addons, tt.updates, tt.success); err != nil {
+				t.Fatal(err)
+			}
+			for series, want := range tt.want {
+				if got, ok := previousValue(path, series); !ok || got != want {
+					t.Errorf("%s: got %g (present %t), want %g", series, got, ok, want)
+				}
+			}
+			for _, series := range tt.missing {
+				if v, ok := previousValue(path, series); ok {
+					t.Errorf("%s: got %g, want no value", series, v)
+				}
+			}
+			if stamp := previousCounter(path, lastSuccessMetric); tt.success && time.Since(time.Unix(int64(stamp), 0)) > time.Minute {
+				t.Errorf("success didn't advance %s, got %g", lastSuccessMetric, stamp)
+			}
+		})
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// notifyOns are the NotifyOn values: always reports every run, changed only
+// runs that installed, found or failed something, error only failed ones.
+var notifyOns = []string{"always", "changed", "error"}
+
+// normalizeNotifyOn lowercases NotifyOn, "" meaning always.
+func normalizeNotifyOn(s string) (string, error) {
+	s = strings.ToLower(s)
+	if s == "" {
+		return "always", nil
+	}
+	for _, n := range notifyOns {
+		if s == n {
+			return s, nil
+		}
+	}
+	return "", errors.Errorf("unknown NotifyOn %q, want one of %s", s, strings.Join(notifyOns, ", "))
+}
+
+// noteworthy tells whether the outcome of e is reported under notifyOn.
+func (e *elvui) noteworthy(notifyOn string) bool {
+	switch notifyOn {
+	case "error":
+		return e.err != nil
+	case "changed":
+		return e.err != nil || e.updated || e.updateAvailable
+	}
+	return true
+}
+
+// notify runs NotifyCommand through the system shell. Its environment carries
+// ELVUIUPDATER_UPDATED and ELVUIUPDATER_FAILED, comma separated addon names,
+// and ELVUIUPDATER_EXIT_CODE.
+func (c *configuration) notify(updaters []*elvui, code int) error {
+	var updated, failed []string
+	for _, e := range updaters {
+		switch {
+		case e.err != nil:
+			failed = append(failed, e.Name)
+		case e.updated || e.updateAvailable:
+			updated = append(updated, e.Name)
+		}
+	}
+	cmd := shellCommand(c.context(), c.NotifyCommand)
+	cmd.Env = append(os.Environ(),
+		"ELVUIUPDATER_UPDATED="+strings.Join(updated, ","),
+		"ELVUIUPDATER_FAILED="+strings.Join(failed, ","),
+		"ELVUIUPDATER_EXIT_CODE="+strconv.Itoa(code),
+	)
+	out, err := cmd.CombinedOutput()
+	if output := strings.TrimSpace(string(out)); output != "" {
+		infof("notify command: %s\n", output)
+	}
+	return errors.Wrap(err, "notify command failed")
+}
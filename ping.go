@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// ping asks every provider endpoint the addons use once, without resolving
+// any release, and prints how each answered. Any HTTP answer means the
+// provider is up, only network errors count as failures. Providers without
+// an endpoint, like exec, are left out. Timeout bounds it like any run.
+func ping(conf *configuration, client *http.Client, addons []addonConfig) int {
+	defer conf.startRun()()
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "PROVIDER\tENDPOINT\tLATENCY\tSTATUS")
+	seen := map[string]bool{}
+	pinged, failures := 0, 0
+	for _, a := range addons {
+		if conf.context().Err() != nil {
+			break
+		}
+		e := &elvui{configuration: conf, addonConfig: a, client: client, log: log.New(os.Stderr, "", log.LstdFlags)}
+		p, err := providerFor(a.Provider)
+		if err != nil {
+			continue
+		}
+		endpoint := p.endpoint(e)
+		if endpoint == "" || seen[endpoint] {
+			continue
+		}
+		seen[endpoint] = true
+		pinged++
+
+		ctx, cancel := context.WithTimeout(conf.context(), e.RequestTimeout.or(30*time.Second))
+		start := time.Now()
+		resp, err := e.head(ctx, endpoint)
+		latency := time.Since(start).Round(time.Millisecond)
+		cancel()
+		status := ""
+		if err != nil {
+			failures++
+			status = "unreachable: " + err.Error()
+		} else {
+			resp.Body.Close()
+			status = resp.Status
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", strings.ToLower(orString(a.Provider, "tukui")), endpoint, latency, status)
+	}
+	w.Flush()
+
+	if conf.context().Err() == context.DeadlineExceeded {
+		errorf("the ping exceeded its %s timeout, %d endpoints were asked\n", time.Duration(conf.Timeout), pinged)
+		return exitTimeout
+	}
+	switch {
+	case failures > 0 && failures == pinged:
+		return exitTotalFailure
+	case failures > 0:
+		return exitPartialFailure
+	}
+	return exitOK
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// defaultProcessNames are the live and classic game executables.
+var defaultProcessNames = []string{"Wow.exe", "WowClassic.exe"}
+
+// closePollInterval is how often -wait-for-close looks at the processes.
+const closePollInterval = 5 * time.Second
+
+// checkGameClosed fails when one of the game executables is running, addon
+// files replaced under a running client only apply after a relaunch anyway.
+// With waitForClose it first waits that long for the game to exit. When the
+// processes cannot be listed, e.g. without ps in a container, the game
+// counts as closed.
+func (c *configuration) checkGameClosed() error {
+	p, err := c.runningGame()
+	if err != nil {
+		c.warnProcessList(err)
+		return nil
+	}
+	if p == "" {
+		return nil
+	}
+	if c.waitForClose <= 0 {
+		return errors.Errorf("%s is running, close the game or pass -allow-running", p)
+	}
+
+	infof("%s is running, waiting up to %s for it to close\n", p, c.waitForClose)
+	start := time.Now()
+	deadline := time.NewTimer(c.waitForClose)
+	defer deadline.Stop()
+	tick := time.NewTicker(closePollInterval)
+	defer tick.Stop()
+	for {
+		select {
+		case <-deadline.C:
+			return errors.Errorf("%s still running after %s", p, c.waitForClose)
+		case <-c.context().Done():
+			return errors.Wrapf(c.context().Err(), "waiting for %s to close", p)
+		case <-tick.C:
+		}
+		if still, err := c.runningGame(); err != nil {
+			// unknown, keep waiting for a list that tells
+			c.warnProcessList(err)
+		} else if still == "" {
+			infof("The game closed after %s\n", time.Since(start).Round(time.Second))
+			return nil
+		}
+	}
+}
+
+// warnProcessList tells, once per run of the program, that the game could
+// not be looked for.
+func (c *configuration) warnProcessList(err error) {
+	c.processListWarning.Do(func() {
+		warnf("%v, cannot tell whether the game is running\n", err)
+	})
+}
+
+// runningGame returns the first running game executable, "" when none is.
+func (c *configuration) runningGame() (string, error) {
+	names := c.ProcessNames
+	if len(names) == 0 {
+		names = defaultProcessNames
+	}
+
+	running, err := runningProcesses()
+	if err != nil {
+		return "", err
+	}
+	for _, p := range running {
+		if containsFold(names, p) {
+			return p, nil
+		}
+	}
+
+	return "", nil
+}
@@ -0,0 +1,24 @@
+package main
+
+import (
+	"runtime"
+	"testing"
+)
+
+// TestCheckGameClosedWithoutProcessList makes sure a missing ps doesn't
+// block every update.
+func TestCheckGameClosedWithoutProcessList(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("lists processes through the Windows API")
+	}
+	t.Setenv("PATH", t.TempDir())
+	if _, err := runningProcesses(); err == nil {
+		t.Fatal("listed processes without ps")
+	}
+	c := &configuration{}
+	for i := 0; i < 2; i++ {
+		if err := c.checkGameClosed(); err != nil {
+			t.Fatalf("got %v, want the update to go ahead", err)
+		}
+	}
+}
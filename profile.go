@@ -0,0 +1,80 @@
+package main
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// importDefaultProfile copies DefaultProfile, a SavedVariables file shipped
+// inside the addon, into the SavedVariables folder of every account under
+// WTF. Accounts already having that file are skipped, it never overwrites.
+func (e *elvui) importDefaultProfile() error {
+	src := filepath.Join(e.addon, e.DefaultProfile)
+	accounts, err := wtfAccounts(filepath.Dir(filepath.Dir(e.addon)))
+	if err != nil {
+		return err
+	}
+	if len(accounts) == 0 {
+		e.verbosef("%s: no WTF account yet, no profile to import\n", e.Name)
+		return nil
+	}
+	for _, account := range accounts {
+		dir := findFold(account, "SavedVariables")
+		dst := filepath.Join(dir, filepath.Base(src))
+		if _, err := os.Lstat(findFold(dir, filepath.Base(src))); err == nil {
+			e.verbosef("%s: %s already has a profile, left alone\n", e.Name, filepath.Base(account))
+			continue
+		}
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return errors.WithStack(err)
+		}
+		if err := copyNew(src, dst); err != nil {
+			return err
+		}
+		e.infof("%s: imported the default profile for %s\n", e.Name, filepath.Base(account))
+	}
+	return nil
+}
+
+// wtfAccounts lists the account folders of WTF/Account in the game folder.
+// The SavedVariables folder shared by all accounts isn't one.
+func wtfAccounts(game string) ([]string, error) {
+	root := findFold(findFold(game, "WTF"), "Account")
+	entries, err := ioutil.ReadDir(root)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	var accounts []string
+	for _, entry := range entries {
+		if entry.IsDir() && !strings.EqualFold(entry.Name(), "SavedVariables") {
+			accounts = append(accounts, filepath.Join(root, entry.Name()))
+		}
+	}
+	return accounts, nil
+}
+
+// copyNew copies src to dst, failing rather than replacing an existing dst.
+func copyNew(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return errors.Wrap(err, "cannot read the default profile")
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		os.Remove(dst)
+		return errors.WithStack(err)
+	}
+	return errors.WithStack(out.Close())
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+type progressKind int
+
+const (
+	downloadStarted progressKind = iota
+	downloadProgress
+	extractStarted
+	extractProgress
+	fileExtracted
+	progressDone
+)
+
+// progressEvent reports how far an addon's download and extraction went.
+// done and total count bytes, total is -1 when the server didn't announce a
+// length. files and totalFiles count the extracted files.
+type progressEvent struct {
+	kind       progressKind
+	addon      string
+	file       string
+	done       int64
+	total      int64
+	files      int
+	totalFiles int
+}
+
+// emit hands ev to the progress callback, if any.
+func (e *elvui) emit(ev progressEvent) {
+	if e.progress != nil {
+		ev.addon = e.Name
+		e.progress(ev)
+	}
+}
+
+// progressWriter reports the bytes written through it as events of its kind.
+type progressWriter struct {
+	e  *elvui
+	ev progressEvent
+}
+
+func (w *progressWriter) Write(p []byte) (int, error) {
+	w.ev.done += int64(len(p))
+	w.e.emit(w.ev)
+	return len(p), nil
+}
+
+// progressBar returns the callback the CLI uses on a terminal, it redraws a
+// single status line at most every 100ms.
+func progressBar() func(progressEvent) {
+	var last time.Time
+	return func(ev progressEvent) {
+		switch ev.kind {
+		case downloadProgress, extractProgress, fileExtracted:
+			if time.Since(last) < 100*time.Millisecond {
+				return
+			}
+		case progressDone:
+			fmt.Fprint(os.Stderr, "\r\x1b[K")
+			return
+		}
+		last = time.Now()
+
+		var line string
+		switch ev.kind {
+		case downloadStarted, downloadProgress:
+			line = fmt.Sprintf("%s: downloading %s", ev.addon, formatBytes(ev.done))
+			if ev.total > 0 {
+				line = fmt.Sprintf("%s: downloading %s/%s (%d%%)", ev.addon, formatBytes(ev.done), formatBytes(ev.total), ev.done*100/ev.total)
+			}
+		case extractStarted, extractProgress, fileExtracted:
+			line = fmt.Sprintf("%s: extracting %d/%d files, %s/%s", ev.addon, ev.files, ev.totalFiles, formatBytes(ev.done), formatBytes(ev.total))
+		}
+		fmt.Fprint(os.Stderr, "\r\x1b[K"+line)
+	}
+}
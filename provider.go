@@ -0,0 +1,743 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// release is the build a provider offers for an addon, its latest one unless
+// -version asks for another.
+type release struct {
+	version version
+	url     string
+	// md5 of the archive when the provider publishes one
+	md5 string
+}
+
+// candidate is one build listed by a provider before selection.
+type candidate struct {
+	version string
+	url     string
+	md5     string
+	flavors []string
+}
+
+// pickHighest selects the highest valid version among the candidates built
+// for the addon's flavor. Candidates with unparsable versions are skipped.
+func (e *elvui) pickHighest(candidates []candidate) (release, error) {
+	var best release
+	var lastErr error
+	found := false
+	for _, c := range candidates {
+		if !flavorMatches(c.flavors, e.Flavor) {
+			continue
+		}
+		v, err := e.parseVersion(c.version)
+		if err != nil {
+			lastErr = errors.Wrapf(err, "cannot parse version number %s", c.version)
+			e.verbosef("%s: skipping candidate, %v\n", e.Name, lastErr)
+			continue
+		}
+		if !found || v.compare(best.version) > 0 {
+			best = release{version: v, url: c.url, md5: c.md5}
+			found = true
+		}
+	}
+
+	if !found {
+		if lastErr != nil {
+			return release{}, lastErr
+		}
+		return release{}, errors.Errorf("no %s build of %s found", e.flavor(), e.Name)
+	}
+	return best, nil
+}
+
+// pickExact selects the candidate built for the addon's flavor at version
+// want, for -version.
+func (e *elvui) pickExact(candidates []candidate, want version) (release, bool) {
+	for _, c := range candidates {
+		if !flavorMatches(c.flavors, e.Flavor) {
+			continue
+		}
+		if v, err := e.parseVersion(c.version); err == nil && v.compare(want) == 0 {
+			return release{version: v, url: c.url, md5: c.md5}, true
+		}
+	}
+	return release{}, false
+}
+
+type provider interface {
+	latest(e *elvui) (release, error)
+	// endpoint is the API URL latest asks, for -ping
+	endpoint(e *elvui) string
+}
+
+// historic is implemented by providers listing past releases too, the
+// others can only serve -version when it is their latest.
+type historic interface {
+	exact(e *elvui, want version) (release, error)
+}
+
+var providers = map[string]provider{
+	"tukui":        tukui{},
+	"wowinterface": wowInterface{},
+	"github":       gitHub{},
+	"curseforge":   curseForge{},
+	"wago":         wago{},
+	"html":         htmlPage{},
+	"exec":         execProvider{},
+}
+
+// providerFor returns the configured provider, tukui when none is set.
+func providerFor(name string) (provider, error) {
+	if name == "" {
+		name = "tukui"
+	}
+	p, ok := providers[strings.ToLower(name)]
+	if !ok {
+		return nil, errors.Errorf("unknown provider %q", name)
+	}
+	return p, nil
+}
+
+// getJSON fetches url with the API client and decodes the body into v.
+// header may carry credentials, so it is never logged.
+func (e *elvui) getJSON(url string, header http.Header, v interface{}) error {
+	body, err := e.get(url, header)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(body, v); err != nil {
+		return errors.WithStack(err)
+	}
+
+	return nil
+}
+
+// maxAPIBody bounds what get reads, API answers are a few KB and anything
+// near this limit is a misbehaving endpoint.
+const maxAPIBody = 1 << 20
+
+// responseCache keeps the API answers of a run, addons sharing an endpoint
+// that lists all of them, such as the tukui all addons one, cost a single
+// request.
+type responseCache struct {
+	mu     sync.Mutex
+	bodies map[string][]byte
+	// fetching holds a lock per key so addons checked at the same time
+	// still share one request
+	fetching map[string]*sync.Mutex
+}
+
+// lock waits for the other requests of key to finish and returns the unlock
+// function.
+func (c *responseCache) lock(key string) func() {
+	c.mu.Lock()
+	if c.fetching == nil {
+		c.fetching = map[string]*sync.Mutex{}
+	}
+	m, ok := c.fetching[key]
+	if !ok {
+		m = &sync.Mutex{}
+		c.fetching[key] = m
+	}
+	c.mu.Unlock()
+	m.Lock()
+	return m.Unlock
+}
+
+func (c *responseCache) lookup(url string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	body, ok := c.bodies[url]
+	return body, ok
+}
+
+// reset forgets the answers, -watch starts every pass afresh.
+func (c *responseCache) reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.bodies = nil
+}
+
+func (c *responseCache) store(url string, body []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.bodies == nil {
+		c.bodies = map[string][]byte{}
+	}
+	c.bodies[url] = body
+}
+
+// cacheKey is url, followed by the addon's Headers when it has some since
+// they may change the answer.
+func (e *elvui) cacheKey(url string) string {
+	if len(e.Headers) == 0 {
+		return url
+	}
+	names := make([]string, 0, len(e.Headers))
+	for name := range e.Headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	key := url
+	for _, name := range names {
+		key += "\n" + http.CanonicalHeaderKey(name) + ": " + e.Headers[name]
+	}
+	return key
+}
+
+// get fetches url with the API client and returns the whole body, an answer
+// other than 2xx is an error. The RequestTimeout context covers reading the
+// body too. Answers are reused for the rest of the run, unless
+// -force-check-all asks for fresh ones from every cache on the way.
+func (e *elvui) get(url string, header http.Header) ([]byte, error) {
+	key := e.cacheKey(url)
+	defer e.responses.lock(key)()
+	if body, ok := e.responses.lookup(key); ok && !e.forceCheck {
+		e.verbosef("%s: reusing the answer of %s\n", e.Name, url)
+		return body, nil
+	}
+	ctx, cancel := context.WithTimeout(e.context(), e.RequestTimeout.or(30*time.Second))
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	for k, vs := range header {
+		// the transport negotiates compression, see decodedBody
+		if http.CanonicalHeaderKey(k) != "Accept-Encoding" {
+			req.Header[k] = vs
+		}
+	}
+	for name, value := range e.Headers {
+		req.Header.Set(name, value)
+	}
+	if e.forceCheck {
+		req.Header.Set("Cache-Control", "no-cache")
+	}
+
+	resp, err := e.do(req)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	defer resp.Body.Close()
+	logRedirect(url, resp)
+	if resp.StatusCode/100 != 2 {
+		return nil, errors.Errorf("%s answers %s", url, resp.Status)
+	}
+
+	decoded, err := decodedBody(resp)
+	if err != nil {
+		return nil, errors.Wrapf(err, "response of %s", url)
+	}
+	body, err := ioutil.ReadAll(io.LimitReader(decoded, maxAPIBody+1))
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if len(body) > maxAPIBody {
+		return nil, errors.Errorf("response of %s exceeds %s", url, formatBytes(maxAPIBody))
+	}
+	e.responses.store(key, body)
+	return body, nil
+}
+
+// tukui reads the tukui.org addon API configured as Page. The endpoint may
+// answer a single addon object, a list of variants or a list of addons, such
+// as the all addons endpoint.
+type tukui struct{}
+
+func (tukui) endpoint(e *elvui) string {
+	if e.channel() == "nightly" {
+		return gitHubAPI + orString(e.Repo, nightlyRepo)
+	}
+	return e.Page
+}
+
+func (tukui) latest(e *elvui) (release, error) {
+	if e.channel() == "nightly" {
+		return e.nightly()
+	}
+	var raw json.RawMessage
+	if err := e.getJSON(e.Page, nil, &raw); err != nil {
+		return release{}, err
+	}
+
+	if e.VersionPath != "" || e.URLPath != "" {
+		return e.pathRelease(raw)
+	}
+
+	var entries []APIResponse
+	if trimmed := bytes.TrimSpace(raw); len(trimmed) > 0 && trimmed[0] == '[' {
+		if err := json.Unmarshal(raw, &entries); err != nil {
+			return release{}, errors.WithStack(err)
+		}
+		own, err := e.ownEntries(entries)
+		if err != nil {
+			return release{}, err
+		}
+		entries = own
+	} else {
+		entries = make([]APIResponse, 1)
+		if err := json.Unmarshal(raw, &entries[0]); err != nil {
+			return release{}, errors.WithStack(err)
+		}
+	}
+
+	candidates := make([]candidate, 0, len(entries))
+	for _, r := range entries {
+		c := candidate{version: r.Version, url: r.URL}
+		if r.Flavor != "" {
+			c.flavors = []string{r.Flavor}
+		}
+		candidates = append(candidates, c)
+	}
+
+	return e.pickHighest(candidates)
+}
+
+// pathRelease reads the version and url of an API response nesting them, at
+// VersionPath and URLPath, each defaulting to the flat field.
+func (e *elvui) pathRelease(raw json.RawMessage) (release, error) {
+	var doc interface{}
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		return release{}, errors.WithStack(err)
+	}
+	version, err := lookupJSONPath(doc, orString(e.VersionPath, "version"))
+	if err != nil {
+		return release{}, errors.Wrap(err, "VersionPath")
+	}
+	url, err := lookupJSONPath(doc, orString(e.URLPath, "url"))
+	if err != nil {
+		return release{}, errors.Wrap(err, "URLPath")
+	}
+	return e.pickHighest([]candidate{{version: version, url: url}})
+}
+
+// nightlyRepo and nightlyBranch hold the development builds of ElvUI, Repo
+// points the nightly channel at another repository.
+const (
+	nightlyRepo   = "tukui-org/ElvUI"
+	nightlyBranch = "development"
+)
+
+// nightly resolves the head of the development branch. Nightly TOCs keep the
+// release version, so the build is versioned by its commit time instead,
+// e.g. 2024.11.05.142233, and the archive of that very commit is installed.
+func (e *elvui) nightly() (release, error) {
+	repo := orString(e.Repo, nightlyRepo)
+	var commit struct {
+		SHA    string `json:"sha"`
+		Commit struct {
+			Committer struct {
+				Date time.Time `json:"date"`
+			} `json:"committer"`
+		} `json:"commit"`
+	}
+	if err := e.getJSON(gitHubAPI+repo+"/commits/"+nightlyBranch, e.gitHubHeader(), &commit); err != nil {
+		return release{}, err
+	}
+	if commit.SHA == "" {
+		return release{}, errors.Errorf("%s has no %s branch head", repo, nightlyBranch)
+	}
+
+	v, err := parseVersionScheme(commit.Commit.Committer.Date.UTC().Format("2006.01.02.150405"), "numeric")
+	if err != nil {
+		return release{}, err
+	}
+	e.verbosef("%s: nightly %s is commit %s\n", e.Name, v, commit.SHA)
+	if e.FlavorPathPrefix == "" {
+		// GitHub wraps commit archives in <name>-<sha>/
+		e.FlavorPathPrefix = repo[strings.LastIndex(repo, "/")+1:] + "-" + commit.SHA
+	}
+	return release{version: v, url: "https://github.com/" + repo + "/archive/" + commit.SHA + ".zip"}, nil
+}
+
+const wowInterfaceAPI = "https://api.mmoui.com/v3/game/WOW/filedetails/"
+
+type wowInterfaceFile struct {
+	UID        string `json:"UID"`
+	UIVersion  string `json:"UIVersion"`
+	UIMD5      string `json:"UIMD5"`
+	UIDownload string `json:"UIDownload"`
+}
+
+// ownEntries keeps the entries of a list describing this addon. Lists of
+// several addons name each entry, the slug must then match AddonID or the
+// addon name, lists of nameless variants are kept whole.
+func (e *elvui) ownEntries(entries []APIResponse) ([]APIResponse, error) {
+	named := false
+	var own []APIResponse
+	for _, r := range entries {
+		if r.Slug == "" && r.Name == "" {
+			continue
+		}
+		named = true
+		if e.AddonID != "" && strings.EqualFold(r.Slug, e.AddonID) ||
+			e.AddonID == "" && (strings.EqualFold(r.Slug, e.Name) || strings.EqualFold(r.Name, e.Name)) {
+			own = append(own, r)
+		}
+	}
+	if !named {
+		return entries, nil
+	}
+	if len(own) == 0 {
+		return nil, errors.Errorf("%s lists no addon named %s", e.Page, e.Name)
+	}
+	return own, nil
+}
+
+// wowInterface resolves AddonID through the WoWInterface (mmoui) API. The API
+// hands out the CDN link directly, skipping the site's interstitial page.
+type wowInterface struct{}
+
+func (wowInterface) endpoint(e *elvui) string {
+	return wowInterfaceAPI + e.AddonID + ".json"
+}
+
+func (wowInterface) latest(e *elvui) (release, error) {
+	if e.AddonID == "" {
+		return release{}, errors.New("wowinterface provider needs AddonID")
+	}
+
+	var files []wowInterfaceFile
+	if err := e.getJSON(wowInterfaceAPI+e.AddonID+".json", nil, &files); err != nil {
+		return release{}, err
+	}
+	if len(files) == 0 {
+		return release{}, errors.Errorf("wowinterface addon %s not found", e.AddonID)
+	}
+
+	f := files[0]
+	v, err := e.parseVersion(f.UIVersion)
+	if err != nil {
+		return release{}, errors.Wrapf(err, "cannot parse version number %s", f.UIVersion)
+	}
+
+	return release{version: v, url: f.UIDownload, md5: strings.ToLower(f.UIMD5)}, nil
+}
+
+const gitHubAPI = "https://api.github.com/repos/"
+
+// gitHubHeader asks for the v3 API, authenticated when a token is set.
+func (e *elvui) gitHubHeader() http.Header {
+	header := http.Header{"Accept": {"application/vnd.github+json"}}
+	if e.GitHubToken != "" {
+		header.Set("Authorization", "Bearer "+e.GitHubToken)
+	}
+	return header
+}
+
+type gitHubRelease struct {
+	TagName string `json:"tag_name"`
+	Assets  []struct {
+		Name string `json:"name"`
+		URL  string `json:"browser_download_url"`
+	} `json:"assets"`
+}
+
+// gitHub takes the first zip asset of the latest release of Repo (owner/name).
+type gitHub struct{}
+
+func (gitHub) endpoint(e *elvui) string {
+	return gitHubAPI + e.Repo
+}
+
+func (gitHub) latest(e *elvui) (release, error) {
+	if e.Repo == "" {
+		return release{}, errors.New("github provider needs Repo")
+	}
+
+	r := &gitHubRelease{}
+	if err := e.getJSON(gitHubAPI+e.Repo+"/releases/latest", e.gitHubHeader(), r); err != nil {
+		return release{}, err
+	}
+
+	v, err := e.parseVersion(r.TagName)
+	if err != nil {
+		return release{}, errors.Wrapf(err, "cannot parse version number %s", r.TagName)
+	}
+	if url := r.zip(); url != "" {
+		return release{version: v, url: url}, nil
+	}
+
+	return release{}, errors.Errorf("release %s of %s has no zip asset", r.TagName, e.Repo)
+}
+
+// exact goes through the releases of Repo, newest first, until one is want.
+func (gitHub) exact(e *elvui, want version) (release, error) {
+	if e.Repo == "" {
+		return release{}, errors.New("github provider needs Repo")
+	}
+
+	for page := 1; ; page++ {
+		var releases []gitHubRelease
+		api := fmt.Sprintf("%s%s/releases?per_page=%d&page=%d", gitHubAPI, e.Repo, gitHubPageSize, page)
+		if err := e.getJSON(api, e.gitHubHeader(), &releases); err != nil {
+			return release{}, err
+		}
+		candidates := make([]candidate, 0, len(releases))
+		for _, r := range releases {
+			if url := r.zip(); url != "" {
+				candidates = append(candidates, candidate{version: r.TagName, url: url})
+			}
+		}
+		if r, ok := e.pickExact(candidates, want); ok {
+			return r, nil
+		}
+		if len(releases) < gitHubPageSize {
+			return release{}, errors.Errorf("%s has no release %s with a zip asset", e.Repo, want)
+		}
+	}
+}
+
+// gitHubPageSize is the most releases the API returns at once.
+const gitHubPageSize = 100
+
+// zip returns the download URL of the first zip asset, "" without one.
+func (r *gitHubRelease) zip() string {
+	for _, a := range r.Assets {
+		if strings.HasSuffix(strings.ToLower(a.Name), ".zip") {
+			return a.URL
+		}
+	}
+	return ""
+}
+
+const curseForgeAPI = "https://api.curseforge.com/v1/mods/"
+
+type curseForgeFile struct {
+	DisplayName  string   `json:"displayName"`
+	FileName     string   `json:"fileName"`
+	DownloadURL  string   `json:"downloadUrl"`
+	ReleaseType  int      `json:"releaseType"`
+	GameVersions []string `json:"gameVersions"`
+}
+
+// curseForge reads the latest files of the mod AddonID, an API key is
+// mandatory for their API.
+type curseForge struct{}
+
+func (curseForge) endpoint(e *elvui) string {
+	return curseForgeAPI + e.AddonID
+}
+
+func (curseForge) latest(e *elvui) (release, error) {
+	if e.AddonID == "" {
+		return release{}, errors.New("curseforge provider needs AddonID")
+	}
+	if e.CurseForgeAPIKey == "" {
+		return release{}, errors.New("curseforge provider needs CurseForgeAPIKey")
+	}
+
+	var mod struct {
+		Data struct {
+			LatestFiles []curseForgeFile `json:"latestFiles"`
+		} `json:"data"`
+	}
+	header := http.Header{"X-Api-Key": {e.CurseForgeAPIKey}}
+	if err := e.getJSON(curseForgeAPI+e.AddonID, header, &mod); err != nil {
+		return release{}, err
+	}
+
+	// releaseType 1 is a stable release, the highest one for our flavor wins
+	var files []curseForgeFile
+	for _, f := range mod.Data.LatestFiles {
+		if f.ReleaseType == 1 {
+			files = append(files, f)
+		}
+	}
+	return e.pickHighest(e.curseForgeCandidates(files))
+}
+
+// curseForgePageSize is the most files the API returns at once.
+const curseForgePageSize = 50
+
+// exact pages through every file of the mod, newest first, whatever its
+// release type, until one is want.
+func (curseForge) exact(e *elvui, want version) (release, error) {
+	if e.AddonID == "" {
+		return release{}, errors.New("curseforge provider needs AddonID")
+	}
+	if e.CurseForgeAPIKey == "" {
+		return release{}, errors.New("curseforge provider needs CurseForgeAPIKey")
+	}
+
+	header := http.Header{"X-Api-Key": {e.CurseForgeAPIKey}}
+	for index := 0; ; index += curseForgePageSize {
+		var files struct {
+			Data []curseForgeFile `json:"data"`
+		}
+		api := fmt.Sprintf("%s%s/files?pageSize=%d&index=%d", curseForgeAPI, e.AddonID, curseForgePageSize, index)
+		if err := e.getJSON(api, header, &files); err != nil {
+			return release{}, err
+		}
+		if r, ok := e.pickExact(e.curseForgeCandidates(files.Data), want); ok {
+			return r, nil
+		}
+		if len(files.Data) < curseForgePageSize {
+			return release{}, errors.Errorf("mod %s has no %s file %s", e.AddonID, e.flavor(), want)
+		}
+	}
+}
+
+// curseForgeCandidates lists the files allowing third party downloads with
+// the flavors of their game versions.
+func (e *elvui) curseForgeCandidates(files []curseForgeFile) []candidate {
+	var candidates []candidate
+	for _, f := range files {
+		if f.DownloadURL == "" {
+			e.verbosef("%s: %s does not allow third party downloads\n", e.Name, f.DisplayName)
+			continue
+		}
+		c := candidate{version: versionPattern.FindString(f.DisplayName), url: f.DownloadURL}
+		for _, gv := range f.GameVersions {
+			if versionPattern.MatchString(gv) {
+				c.flavors = append(c.flavors, gameVersionFlavor(gv))
+			}
+		}
+		candidates = append(candidates, c)
+	}
+	return candidates
+}
+
+const wagoAPI = "https://addons.wago.io/api/external/addons/"
+
+// wagoGameVersions maps our flavors to the game_version values of Wago.
+var wagoGameVersions = map[string]string{
+	"retail":  "retail",
+	"classic": "classic",
+	"tbc":     "bc",
+	"wrath":   "wotlk",
+	"cata":    "cata",
+	"mists":   "mop",
+}
+
+type wagoRelease struct {
+	Label string `json:"label"`
+	Link  string `json:"link"`
+}
+
+// wago resolves the addon slug AddonID on addons.wago.io. A beta Channel also
+// accepts stable builds and alpha accepts everything, the highest one wins.
+type wago struct{}
+
+func (wago) endpoint(e *elvui) string {
+	return wagoAPI + url.PathEscape(e.AddonID)
+}
+
+func (wago) latest(e *elvui) (release, error) {
+	if e.AddonID == "" {
+		return release{}, errors.New("wago provider needs AddonID")
+	}
+	if e.WagoAPIKey == "" {
+		return release{}, errors.New("wago provider needs WagoAPIKey")
+	}
+
+	var addon struct {
+		RecentRelease map[string]*wagoRelease `json:"recent_release"`
+	}
+	u := wagoAPI + url.PathEscape(e.AddonID) + "?game_version=" + wagoGameVersions[e.flavor()]
+	header := http.Header{"Authorization": {"Bearer " + e.WagoAPIKey}}
+	if err := e.getJSON(u, header, &addon); err != nil {
+		return release{}, err
+	}
+
+	var candidates []candidate
+	for _, channel := range channelsUpTo(e.Channel) {
+		if r := addon.RecentRelease[channel]; r != nil && r.Link != "" {
+			candidates = append(candidates, candidate{version: r.Label, url: r.Link})
+		}
+	}
+	if len(candidates) == 0 {
+		return release{}, errors.Errorf("wago addon %s has no %s release", e.AddonID, e.channel())
+	}
+
+	return e.pickHighest(candidates)
+}
+
+// htmlPage scrapes Page with PagePattern for sources without an API. The
+// pattern names its groups version and url, a relative url is resolved
+// against Page. Every match is a candidate.
+type htmlPage struct{}
+
+func (htmlPage) endpoint(e *elvui) string {
+	return e.Page
+}
+
+func (htmlPage) latest(e *elvui) (release, error) {
+	pattern, err := compilePagePattern(e.PagePattern)
+	if err != nil {
+		return release{}, err
+	}
+	base, err := url.Parse(e.Page)
+	if err != nil {
+		return release{}, errors.Wrapf(err, "invalid page %s", e.Page)
+	}
+	body, err := e.get(e.Page, nil)
+	if err != nil {
+		return release{}, err
+	}
+
+	var candidates []candidate
+	for _, m := range pattern.FindAllSubmatch(body, -1) {
+		c := candidate{}
+		for i, name := range pattern.SubexpNames() {
+			switch name {
+			case "version":
+				c.version = string(m[i])
+			case "url":
+				link, err := base.Parse(string(m[i]))
+				if err != nil {
+					e.verbosef("%s: skipping link %s, %v\n", e.Name, m[i], err)
+					continue
+				}
+				c.url = link.String()
+			}
+		}
+		if c.url != "" {
+			candidates = append(candidates, c)
+		}
+	}
+	if len(candidates) == 0 {
+		return release{}, errors.Errorf("PagePattern matched nothing on %s", e.Page)
+	}
+
+	return e.pickHighest(candidates)
+}
+
+// compilePagePattern compiles PagePattern and checks it captures both the
+// version and the url group.
+func compilePagePattern(expr string) (*regexp.Regexp, error) {
+	if expr == "" {
+		return nil, errors.New("the html provider needs a PagePattern")
+	}
+	pattern, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid PagePattern")
+	}
+	groups := map[string]bool{}
+	for _, name := range pattern.SubexpNames() {
+		groups[name] = true
+	}
+	for _, name := range []string{"version", "url"} {
+		if !groups[name] {
+			return nil, errors.Errorf("PagePattern lacks a (?P<%s>...) group", name)
+		}
+	}
+	return pattern, nil
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"compress/gzip"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// TestGetGzipWithHeaders serves a gzip API answer whatever the request asks
+// for, the custom headers must not cost the decompression.
+func TestGetGzipWithHeaders(t *testing.T) {
+	tests := []struct {
+		name string
+		// transportGzip is whether the transport asks for gzip itself
+		transportGzip bool
+		wantEncoding  string
+	}{
+		{name: "transport decodes", transportGzip: true, wantEncoding: "gzip"},
+		{name: "decodedBody decodes", transportGzip: false, wantEncoding: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if got := r.Header.Get("Accept-Encoding"); got != tt.wantEncoding {
+					t.Errorf("server got Accept-Encoding %q, want %q", got, tt.wantEncoding)
+				}
+				if got := r.Header.Get("X-Guild"); got != "Tempest" {
+					t.Errorf("server got X-Guild %q, want Tempest", got)
+				}
+				if got := r.Header.Get("Accept"); got != "application/json" {
+					t.Errorf("server got Accept %q, want application/json", got)
+				}
+				w.Header().Set("Content-Type", "application/json")
+				w.Header().Set("Content-Encoding", "gzip")
+				gz := gzip.NewWriter(w)
+				gz.Write([]byte(`{"version":"13.06","url":"https://example.com/elvui.zip"}`))
+				gz.Close()
+			}))
+			defer server.Close()
+
+			client := server.Client()
+			client.Transport.(*http.Transport).DisableCompression = !tt.transportGzip
+			e := &elvui{
+				configuration: &configuration{},
+				addonConfig:   addonConfig{Name: "ElvUI", Headers: map[string]string{"X-Guild": "Tempest"}},
+				client:        client,
+			}
+			e.log = log.New(ioutil.Discard, "", 0)
+
+			header := http.Header{"Accept": {"application/json"}, "Accept-Encoding": {"identity"}}
+			var got APIResponse
+			if err := e.getJSON(server.URL+"/api", header, &got); err != nil {
+				t.Fatal(err)
+			}
+			if got.Version != "13.06" || got.URL != "https://example.com/elvui.zip" {
+				t.Errorf("got %+v", got)
+			}
+		})
+	}
+}
+
+// TestGetNotOK makes sure error pages don't reach the JSON decoders.
+func TestGetNotOK(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"message":"unauthorized"}`, http.StatusUnauthorized)
+	}))
+	defer server.Close()
+	e := &elvui{configuration: &configuration{}, addonConfig: addonConfig{Name: "ElvUI"}, client: server.Client()}
+	e.log = log.New(ioutil.Discard, "", 0)
+
+	// twice, an error page isn't cached either
+	for i := 0; i < 2; i++ {
+		var got APIResponse
+		err := e.getJSON(server.URL+"/api", nil, &got)
+		if want := server.URL + "/api answers 401 Unauthorized"; err == nil || err.Error() != want {
+			t.Fatalf("got error %v, want %q", err, want)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"archive/zip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+)
+
+// repair re-extracts the files of the last install that are missing or
+// modified on disk and leaves everything else alone. Without a recorded
+// manifest, or when the provider no longer offers the installed version, it
+// falls back to a full reinstall.
+func (e *elvui) repair(opts options, st *state) (bool, error) {
+	if _, err := os.Stat(st.manifestPath(e.Name)); os.IsNotExist(err) {
+		e.infof("%s: no manifest recorded, reinstalling\n", e.Name)
+		opts.reinstall = true
+		return e.update(opts, st)
+	}
+	expected, err := st.loadManifest(e.Name)
+	if err != nil {
+		return false, err
+	}
+	installed, err := e.scanInstalled(expected)
+	if err != nil {
+		return false, err
+	}
+	missing, _, modified := expected.compare(installed)
+	broken := append(missing, modified...)
+	if len(broken) == 0 {
+		e.infof("%s: %d files intact, nothing to repair\n", e.Name, len(expected))
+		return false, nil
+	}
+
+	if err := e.setRemoteVersionNDownloadURL(); err != nil {
+		return false, err
+	}
+	if e.remoteVersion.compare(e.localVersion) != 0 {
+		e.infof("%s: %d files damaged but the provider offers %s, not %s, reinstalling\n", e.Name, len(broken), e.remoteVersion, e.localVersion)
+		opts.reinstall = true
+		return e.update(opts, st)
+	}
+	if !e.allowRunning {
+		if err := e.checkGameClosed(); err != nil {
+			return false, err
+		}
+	}
+
+	defer e.emit(progressEvent{kind: progressDone})
+	archive, size, err := e.download()
+	if err != nil {
+		return false, err
+	}
+	defer os.Remove(archive.Name())
+	defer archive.Close()
+	e.downloaded += size
+	zipReader, err := e.openArchive(archive, size)
+	if err != nil {
+		return false, err
+	}
+	entries := map[string]*zip.File{}
+	for _, f := range zipReader.File {
+		entries[f.Name] = f
+	}
+
+	staging, err := ioutil.TempDir(e.TempDir, "elvuiUpdater-")
+	if err != nil {
+		return false, errors.Wrap(err, "cannot create staging directory")
+	}
+	defer os.RemoveAll(staging)
+	for _, path := range broken {
+		f, ok := entries[path]
+		if !ok {
+			return false, errors.Errorf("archive of %s lacks %s", e.remoteVersion, path)
+		}
+		sum, err := e.extractFile(f, staging, ioutil.Discard)
+		if err != nil {
+			return false, err
+		}
+		if sum != expected[path] {
+			return false, errors.Errorf("%s in the archive doesn't match the last install", path)
+		}
+	}
+
+	for _, path := range broken {
+		src, dst := filepath.Join(staging, path), filepath.Join(e.addon, filepath.FromSlash(path))
+		if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+			return false, errors.Wrapf(err, "cannot create directory for %s", dst)
+		}
+		if err := e.removeAll(dst); err != nil {
+			return false, errors.Wrapf(err, "cannot remove %s", dst)
+		}
+		if err := move(src, dst); err != nil {
+			return false, err
+		}
+		e.infof("  * %s\n", path)
+	}
+	e.extracted += len(broken)
+	e.infof("%s: %s %d files\n", e.Name, paint(colorGreen, tr("Repaired")), len(broken))
+
+	return false, nil
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// addonState is what we remember about an addon between runs.
+type addonState struct {
+	LastCheck  time.Time `json:"lastCheck"`
+	LastUpdate time.Time `json:"lastUpdate"`
+	// Build is the installed nightly build, its TOC can't tell
+	Build string `json:"build,omitempty"`
+	// Failed is set when the last run of the addon ended in an error,
+	// -since doesn't leave it out then
+	Failed bool `json:"failed,omitempty"`
+}
+
+type state struct {
+	Addons map[string]*addonState `json:"addons"`
+	path   string
+	// mu guards Addons while addons are processed concurrently
+	mu sync.Mutex
+}
+
+// loadState reads the state file at path, a missing file is an empty state.
+func loadState(path string) (*state, error) {
+	s := &state{Addons: map[string]*addonState{}, path: path}
+
+	raw, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	} else if err != nil {
+		return nil, errors.Wrapf(err, "cannot read state file %s", path)
+	}
+	if err := json.Unmarshal(raw, s); err != nil {
+		return nil, errors.Wrapf(err, "cannot unmarshal state file %s", path)
+	}
+	if s.Addons == nil {
+		s.Addons = map[string]*addonState{}
+	}
+
+	return s, nil
+}
+
+// defaultStateDir is %LOCALAPPDATA%\elvuiUpdater on Windows,
+// ~/Library/Application Support/elvuiUpdater on macOS and
+// $XDG_STATE_HOME/elvuiUpdater (~/.local/state) elsewhere.
+func defaultStateDir() (string, error) {
+	switch base := os.Getenv("XDG_STATE_HOME"); {
+	case runtime.GOOS == "windows":
+		base = os.Getenv("LOCALAPPDATA")
+		if base == "" {
+			return "", errors.New("LOCALAPPDATA is not set, set StateDir")
+		}
+		return filepath.Join(base, "elvuiUpdater"), nil
+	case runtime.GOOS == "darwin":
+		base, err := os.UserConfigDir()
+		if err != nil {
+			return "", errors.Wrap(err, "cannot locate the state directory, set StateDir")
+		}
+		return filepath.Join(base, "elvuiUpdater"), nil
+	case base != "":
+		return filepath.Join(base, "elvuiUpdater"), nil
+	default:
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", errors.Wrap(err, "cannot locate the state directory, set StateDir")
+		}
+		return filepath.Join(home, ".local", "state", "elvuiUpdater"), nil
+	}
+}
+
+// openState loads the state kept in dir, creating it. The state.json and
+// manifests that older versions left in legacyDir are moved over first.
+func openState(dir, legacyDir string) (*state, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, errors.Wrapf(err, "cannot create state directory %s", dir)
+	}
+	for _, name := range []string{"state.json", "manifests"} {
+		src, dst := filepath.Join(legacyDir, name), filepath.Join(dir, name)
+		if _, err := os.Stat(src); err != nil || sameFile(src, dst) {
+			continue
+		}
+		if _, err := os.Stat(dst); err == nil {
+			continue
+		}
+		infof("Moving %s to %s\n", src, dst)
+		if err := move(src, dst); err != nil {
+			return nil, err
+		}
+		// move copies across volumes, leaving the original behind
+		os.RemoveAll(src)
+	}
+	return loadState(filepath.Join(dir, "state.json"))
+}
+
+// sameFile reports whether a and b are the same path once made absolute.
+func sameFile(a, b string) bool {
+	a, errA := filepath.Abs(a)
+	b, errB := filepath.Abs(b)
+	return errA == nil && errB == nil && a == b
+}
+
+func (s *state) addon(name string) *addonState {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	a, ok := s.Addons[name]
+	if !ok {
+		a = &addonState{}
+		s.Addons[name] = a
+	}
+	return a
+}
+
+func (s *state) save() error {
+	raw, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "cannot marshal state")
+	}
+	if err := ioutil.WriteFile(s.path, raw, 0644); err != nil {
+		return errors.Wrapf(err, "cannot write state file %s", s.path)
+	}
+	return nil
+}
+
+// formatTime renders t for humans, "never" for the zero time.
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return "never"
+	}
+	return t.Local().Format("2006-01-02 15:04:05")
+}
+
+// checkpointPath lists the addons done by a run still in progress.
+func (s *state) checkpointPath() string {
+	return filepath.Join(filepath.Dir(s.path), "checkpoint.json")
+}
+
+// loadCheckpoint returns the addons the last unfinished run got through,
+// none when it finished.
+func (s *state) loadCheckpoint() ([]string, error) {
+	raw, err := ioutil.ReadFile(s.checkpointPath())
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, errors.Wrapf(err, "cannot read checkpoint %s", s.checkpointPath())
+	}
+	var done []string
+	if err := json.Unmarshal(raw, &done); err != nil {
+		return nil, errors.Wrapf(err, "cannot parse checkpoint %s", s.checkpointPath())
+	}
+	return done, nil
+}
+
+func (s *state) saveCheckpoint(done []string) error {
+	raw, err := json.Marshal(done)
+	if err != nil {
+		return errors.Wrap(err, "cannot marshal checkpoint")
+	}
+	if err := ioutil.WriteFile(s.checkpointPath(), raw, 0644); err != nil {
+		return errors.Wrapf(err, "cannot write checkpoint %s", s.checkpointPath())
+	}
+	return nil
+}
+
+func (s *state) clearCheckpoint() error {
+	if err := os.Remove(s.checkpointPath()); err != nil && !os.IsNotExist(err) {
+		return errors.Wrapf(err, "cannot remove checkpoint %s", s.checkpointPath())
+	}
+	return nil
+}
@@ -0,0 +1,40 @@
+package main
+
+// The STATUS lines are meant for scripts: they are never translated nor
+// colored and read "STATUS: <status> <addon>", then "STATUS: exit <code>
+// <outcome>" once the run is over. Addons left out of the run altogether
+// are excluded or disabled.
+
+// status tells what became of e: error, updated, update-available (-check
+// only), up-to-date, or skipped when a newer release was left alone, e.g.
+// below MinVersion or refused by PreUpdateHook.
+func (e *elvui) status() string {
+	switch {
+	case e.err != nil:
+		return "error"
+	case e.updated:
+		return "updated"
+	case e.updateAvailable:
+		return "update-available"
+	case e.remoteVersion.compare(e.localVersion) > 0:
+		return "skipped"
+	}
+	return "up-to-date"
+}
+
+// exitStatus names an exit code.
+func exitStatus(code int) string {
+	switch code {
+	case exitOK:
+		return "ok"
+	case exitPartialFailure:
+		return "partial-failure"
+	case exitTotalFailure:
+		return "total-failure"
+	case exitTimeout:
+		return "timeout"
+	case exitUpdateAvailable:
+		return "update-available"
+	}
+	return "unknown"
+}
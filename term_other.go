@@ -0,0 +1,29 @@
+//go:build !windows
+// +build !windows
+
+package main
+
+import (
+	"os"
+	"os/exec"
+)
+
+// enableTerminalColor reports whether f is a terminal, which renders ANSI
+// colors as is.
+func enableTerminalColor(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0 && os.Getenv("TERM") != "dumb"
+}
+
+// noEcho stops a terminal from echoing what is typed until restore is called.
+func noEcho(f *os.File) (restore func()) {
+	stty := func(arg string) error {
+		cmd := exec.Command("stty", arg)
+		cmd.Stdin = f
+		return cmd.Run()
+	}
+	if info, err := f.Stat(); err != nil || info.Mode()&os.ModeCharDevice == 0 || stty("-echo") != nil {
+		return func() {}
+	}
+	return func() { stty("echo") }
+}
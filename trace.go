@@ -0,0 +1,116 @@
+package main
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"net/http/httptrace"
+	"strings"
+	"sync"
+	"time"
+)
+
+// connTrace collects the transport events of a request for -verbose: how the
+// connection was made and how long each phase took. Redirects go through it
+// too, the last hop is what gets reported.
+type connTrace struct {
+	mu                            sync.Mutex
+	start                         time.Time
+	dns, connect, tls, ttfb       time.Duration
+	dnsStart, connStart, tlsStart time.Time
+	remote                        string
+	reused                        bool
+}
+
+func (t *connTrace) clientTrace() *httptrace.ClientTrace {
+	// the dialer may race several addresses, hence the lock
+	since := func(at time.Time) time.Duration { return time.Since(at).Round(time.Millisecond) }
+	record := func(f func()) { t.mu.Lock(); defer t.mu.Unlock(); f() }
+	return &httptrace.ClientTrace{
+		GetConn: func(string) {
+			record(func() {
+				t.start, t.dnsStart, t.connStart = time.Now(), time.Time{}, time.Time{}
+				t.dns, t.connect, t.tls, t.ttfb = 0, 0, 0, 0
+			})
+		},
+		DNSStart: func(httptrace.DNSStartInfo) { record(func() { t.dnsStart = time.Now() }) },
+		DNSDone:  func(httptrace.DNSDoneInfo) { record(func() { t.dns = since(t.dnsStart) }) },
+		ConnectStart: func(string, string) {
+			record(func() {
+				if t.connStart.IsZero() {
+					t.connStart = time.Now()
+				}
+			})
+		},
+		ConnectDone: func(_, _ string, err error) {
+			record(func() {
+				if err == nil {
+					t.connect = since(t.connStart)
+				}
+			})
+		},
+		TLSHandshakeStart: func() { record(func() { t.tlsStart = time.Now() }) },
+		TLSHandshakeDone:  func(tls.ConnectionState, error) { record(func() { t.tls = since(t.tlsStart) }) },
+		GotConn: func(info httptrace.GotConnInfo) {
+			record(func() {
+				t.reused = info.Reused
+				t.remote = info.Conn.RemoteAddr().String()
+			})
+		},
+		GotFirstResponseByte: func() { record(func() { t.ttfb = since(t.start) }) },
+	}
+}
+
+// String reads e.g. "HTTP/2.0, TLS 1.3, 140.82.121.4:443, dns 12ms, connect
+// 20ms, tls 35ms, first byte 180ms". Through a proxy the address is the
+// proxy's.
+func (t *connTrace) String(resp *http.Response) string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	parts := []string{resp.Proto}
+	if resp.TLS != nil {
+		parts = append(parts, tlsVersionName(resp.TLS.Version))
+	}
+	parts = append(parts, t.remote)
+	if t.reused {
+		parts = append(parts, "reused connection")
+	} else {
+		if t.dns > 0 {
+			parts = append(parts, "dns "+t.dns.String())
+		}
+		parts = append(parts, "connect "+t.connect.String())
+		if resp.TLS != nil {
+			parts = append(parts, "tls "+t.tls.String())
+		}
+	}
+	return strings.Join(append(parts, "first byte "+t.ttfb.String()), ", ")
+}
+
+func tlsVersionName(v uint16) string {
+	switch v {
+	case tls.VersionTLS10:
+		return "TLS 1.0"
+	case tls.VersionTLS11:
+		return "TLS 1.1"
+	case tls.VersionTLS12:
+		return "TLS 1.2"
+	case tls.VersionTLS13:
+		return "TLS 1.3"
+	}
+	return fmt.Sprintf("TLS 0x%04x", v)
+}
+
+// do sends req with the shared client. Under -verbose it also logs how the
+// response came in, tracing costs nothing otherwise.
+func (e *elvui) do(req *http.Request) (*http.Response, error) {
+	if !verbose || level > levelInfo {
+		return e.client.Do(req)
+	}
+	t := &connTrace{}
+	req = req.WithContext(httptrace.WithClientTrace(req.Context(), t.clientTrace()))
+	resp, err := e.client.Do(req)
+	if err == nil {
+		e.verbosef("%s: %s %s: %s\n", e.Name, req.Method, resp.Request.URL.Redacted(), t.String(resp))
+	}
+	return resp, err
+}
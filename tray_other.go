@@ -0,0 +1,12 @@
+//go:build !windows
+// +build !windows
+
+package main
+
+import "time"
+
+// runTray needs the Windows notification area.
+func (b *batch) runTray(interval time.Duration, jitter float64) int {
+	fatalf("-tray is only available on Windows, use -watch instead\n")
+	return exitTotalFailure
+}
@@ -0,0 +1,369 @@
+//go:build windows
+// +build windows
+
+package main
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+	"time"
+	"unsafe"
+
+	"github.com/pkg/errors"
+	"golang.org/x/sys/windows"
+)
+
+var (
+	user32                    = windows.NewLazySystemDLL("user32.dll")
+	shell32                   = windows.NewLazySystemDLL("shell32.dll")
+	kernel32                  = windows.NewLazySystemDLL("kernel32.dll")
+	procRegisterClassEx       = user32.NewProc("RegisterClassExW")
+	procCreateWindowEx        = user32.NewProc("CreateWindowExW")
+	procDefWindowProc         = user32.NewProc("DefWindowProcW")
+	procDestroyWindow         = user32.NewProc("DestroyWindow")
+	procGetMessage            = user32.NewProc("GetMessageW")
+	procTranslateMessage      = user32.NewProc("TranslateMessage")
+	procDispatchMessage       = user32.NewProc("DispatchMessageW")
+	procPostMessage           = user32.NewProc("PostMessageW")
+	procPostQuitMessage       = user32.NewProc("PostQuitMessage")
+	procRegisterWindowMessage = user32.NewProc("RegisterWindowMessageW")
+	procLoadIcon              = user32.NewProc("LoadIconW")
+	procCreatePopupMenu       = user32.NewProc("CreatePopupMenu")
+	procAppendMenu            = user32.NewProc("AppendMenuW")
+	procTrackPopupMenu        = user32.NewProc("TrackPopupMenu")
+	procDestroyMenu           = user32.NewProc("DestroyMenu")
+	procGetCursorPos          = user32.NewProc("GetCursorPos")
+	procSetForegroundWindow   = user32.NewProc("SetForegroundWindow")
+	procShowWindow            = user32.NewProc("ShowWindow")
+	procShellNotifyIcon       = shell32.NewProc("Shell_NotifyIconW")
+	procGetModuleHandle       = kernel32.NewProc("GetModuleHandleW")
+	procGetConsoleWindow      = kernel32.NewProc("GetConsoleWindow")
+	procGetConsoleProcessList = kernel32.NewProc("GetConsoleProcessList")
+)
+
+const (
+	wmDestroy       = 0x0002
+	wmClose         = 0x0010
+	wmContextMenu   = 0x007b
+	wmLButtonDblClk = 0x0203
+	wmRButtonUp     = 0x0205
+	wmTray          = 0x8000 + 1 // WM_APP + 1, the icon's callback message
+
+	nimAdd     = 0
+	nimModify  = 1
+	nimDelete  = 2
+	nifMessage = 0x1
+	nifIcon    = 0x2
+	nifTip     = 0x4
+	nifInfo    = 0x10
+	niifInfo   = 0x1
+
+	mfString       = 0x0
+	mfSeparator    = 0x800
+	tpmRightButton = 0x2
+	tpmNoNotify    = 0x80
+	tpmReturnCmd   = 0x100
+	idiApplication = 32512
+	swHide         = 0
+)
+
+// the tray menu items
+const (
+	menuCheck = 1 + iota
+	menuUpdate
+	menuExit
+)
+
+// notifyIconData mirrors NOTIFYICONDATAW from shellapi.h.
+type notifyIconData struct {
+	Size            uint32
+	Wnd             windows.Handle
+	ID              uint32
+	Flags           uint32
+	CallbackMessage uint32
+	Icon            windows.Handle
+	Tip             [128]uint16
+	State           uint32
+	StateMask       uint32
+	Info            [256]uint16
+	Version         uint32
+	InfoTitle       [64]uint16
+	InfoFlags       uint32
+	GUIDItem        windows.GUID
+	BalloonIcon     windows.Handle
+}
+
+// wndClassEx mirrors WNDCLASSEXW from winuser.h.
+type wndClassEx struct {
+	Size       uint32
+	Style      uint32
+	WndProc    uintptr
+	ClsExtra   int32
+	WndExtra   int32
+	Instance   windows.Handle
+	Icon       windows.Handle
+	Cursor     windows.Handle
+	Background windows.Handle
+	MenuName   *uint16
+	ClassName  *uint16
+	IconSm     windows.Handle
+}
+
+type point struct{ X, Y int32 }
+
+// winMsg mirrors MSG from winuser.h.
+type winMsg struct {
+	Wnd     windows.Handle
+	Message uint32
+	WParam  uintptr
+	LParam  uintptr
+	Time    uint32
+	Pt      point
+}
+
+// trayRequest is a run asked for from the tray menu.
+type trayRequest int
+
+const (
+	trayCheck trayRequest = iota
+	trayUpdate
+)
+
+// trayInterval is how often -tray checks when -watch doesn't say.
+const trayInterval = 6 * time.Hour
+
+// trayIcon is the notification area icon of -tray. Its hidden window gets
+// the icon's mouse events, requests hands the runs they ask for to the
+// worker.
+type trayIcon struct {
+	wnd      windows.Handle
+	data     notifyIconData
+	requests chan trayRequest
+	// taskbarCreated is broadcast when Explorer restarts, the icon must be
+	// added anew then
+	taskbarCreated uint32
+}
+
+// tray is the one icon of the process, the window procedure has no other way
+// to reach it.
+var tray *trayIcon
+
+// runTray checks every interval from the notification area, tells about
+// the updates found and installs them from the menu's "Update now". The
+// command line options apply to the update as usual.
+func (b *batch) runTray(interval time.Duration, jitter float64) int {
+	if interval <= 0 {
+		interval = trayInterval
+	}
+	// the window and its message loop must stay on one thread
+	runtime.LockOSThread()
+	hideOwnConsole()
+	t, err := newTrayIcon()
+	if err != nil {
+		fatalf("%+v\n", err)
+		return exitTotalFailure
+	}
+	tray = t
+	go b.trayWorker(t, interval, jitter)
+	t.loop()
+	return exitOK
+}
+
+// hideOwnConsole hides the console window Windows opened for the process,
+// one shared with a terminal is left alone.
+func hideOwnConsole() {
+	console, _, _ := procGetConsoleWindow.Call()
+	if console == 0 {
+		return
+	}
+	var pids [2]uint32
+	if n, _, _ := procGetConsoleProcessList.Call(uintptr(unsafe.Pointer(&pids[0])), uintptr(len(pids))); n == 1 {
+		procShowWindow.Call(console, swHide)
+	}
+}
+
+func newTrayIcon() (*trayIcon, error) {
+	className, _ := windows.UTF16PtrFromString("elvuiUpdaterTray")
+	instance, _, _ := procGetModuleHandle.Call(0)
+	class := wndClassEx{
+		WndProc:   windows.NewCallback(trayWndProc),
+		Instance:  windows.Handle(instance),
+		ClassName: className,
+	}
+	class.Size = uint32(unsafe.Sizeof(class))
+	if r, _, err := procRegisterClassEx.Call(uintptr(unsafe.Pointer(&class))); r == 0 {
+		return nil, errors.Wrap(err, "cannot register the tray window class")
+	}
+	wnd, _, err := procCreateWindowEx.Call(0, uintptr(unsafe.Pointer(className)), uintptr(unsafe.Pointer(className)),
+		0, 0, 0, 0, 0, 0, 0, instance, 0)
+	if wnd == 0 {
+		return nil, errors.Wrap(err, "cannot create the tray window")
+	}
+	icon, _, _ := procLoadIcon.Call(0, idiApplication)
+	message, _ := windows.UTF16PtrFromString("TaskbarCreated")
+	taskbarCreated, _, _ := procRegisterWindowMessage.Call(uintptr(unsafe.Pointer(message)))
+
+	t := &trayIcon{wnd: windows.Handle(wnd), requests: make(chan trayRequest, 1), taskbarCreated: uint32(taskbarCreated)}
+	t.data.Size = uint32(unsafe.Sizeof(t.data))
+	t.data.Wnd = t.wnd
+	t.data.ID = 1
+	t.data.Flags = nifMessage | nifIcon | nifTip
+	t.data.CallbackMessage = wmTray
+	t.data.Icon = windows.Handle(icon)
+	copy(t.data.Tip[:len(t.data.Tip)-1], windows.StringToUTF16("elvuiUpdater"))
+	if r, _, err := procShellNotifyIcon.Call(nimAdd, uintptr(unsafe.Pointer(&t.data))); r == 0 {
+		procDestroyWindow.Call(wnd)
+		return nil, errors.Wrap(err, "cannot add the tray icon")
+	}
+	return t, nil
+}
+
+// loop pumps the window messages until Exit is picked.
+func (t *trayIcon) loop() {
+	var m winMsg
+	for {
+		if r, _, _ := procGetMessage.Call(uintptr(unsafe.Pointer(&m)), 0, 0, 0); int32(r) <= 0 {
+			return
+		}
+		procTranslateMessage.Call(uintptr(unsafe.Pointer(&m)))
+		procDispatchMessage.Call(uintptr(unsafe.Pointer(&m)))
+	}
+}
+
+func trayWndProc(wnd windows.Handle, msg uint32, wParam, lParam uintptr) uintptr {
+	t := tray
+	switch {
+	case t == nil:
+	case msg == wmTray && (lParam == wmRButtonUp || lParam == wmContextMenu):
+		t.menu()
+		return 0
+	case msg == wmTray && lParam == wmLButtonDblClk:
+		t.request(trayCheck)
+		return 0
+	case msg == t.taskbarCreated:
+		procShellNotifyIcon.Call(nimAdd, uintptr(unsafe.Pointer(&t.data)))
+		return 0
+	case msg == wmDestroy:
+		procShellNotifyIcon.Call(nimDelete, uintptr(unsafe.Pointer(&t.data)))
+		procPostQuitMessage.Call(0)
+		return 0
+	}
+	r, _, _ := procDefWindowProc.Call(uintptr(wnd), uintptr(msg), wParam, lParam)
+	return r
+}
+
+// menu shows the right-click menu at the cursor and acts on the pick.
+func (t *trayIcon) menu() {
+	menu, _, _ := procCreatePopupMenu.Call()
+	if menu == 0 {
+		return
+	}
+	defer procDestroyMenu.Call(menu)
+	for _, item := range []struct {
+		id    uintptr
+		label string
+	}{{menuCheck, tr("Check now")}, {menuUpdate, tr("Update now")}, {0, ""}, {menuExit, tr("Exit")}} {
+		if item.id == 0 {
+			procAppendMenu.Call(menu, mfSeparator, 0, 0)
+			continue
+		}
+		label, _ := windows.UTF16PtrFromString(item.label)
+		procAppendMenu.Call(menu, mfString, item.id, uintptr(unsafe.Pointer(label)))
+	}
+	var p point
+	procGetCursorPos.Call(uintptr(unsafe.Pointer(&p)))
+	// without it the menu doesn't close when clicking elsewhere
+	procSetForegroundWindow.Call(uintptr(t.wnd))
+	picked, _, _ := procTrackPopupMenu.Call(menu, tpmRightButton|tpmReturnCmd|tpmNoNotify, uintptr(p.X), uintptr(p.Y), 0, uintptr(t.wnd), 0)
+	switch picked {
+	case menuCheck:
+		t.request(trayCheck)
+	case menuUpdate:
+		t.request(trayUpdate)
+	case menuExit:
+		procPostMessage.Call(uintptr(t.wnd), wmClose, 0, 0)
+	}
+}
+
+// request queues a run without blocking the window, one asked for while
+// another waits is dropped.
+func (t *trayIcon) request(r trayRequest) {
+	select {
+	case t.requests <- r:
+	default:
+	}
+}
+
+// setTip replaces the icon's tooltip.
+func (t *trayIcon) setTip(tip string) {
+	data := t.data
+	data.Flags = nifTip
+	data.Tip = [len(data.Tip)]uint16{}
+	copy(data.Tip[:len(data.Tip)-1], windows.StringToUTF16(tip))
+	procShellNotifyIcon.Call(nimModify, uintptr(unsafe.Pointer(&data)))
+}
+
+// balloon shows a notification from the icon.
+func (t *trayIcon) balloon(title, text string) {
+	data := t.data
+	data.Flags = nifInfo
+	data.InfoFlags = niifInfo
+	copy(data.InfoTitle[:len(data.InfoTitle)-1], windows.StringToUTF16(title))
+	copy(data.Info[:len(data.Info)-1], windows.StringToUTF16(text))
+	procShellNotifyIcon.Call(nimModify, uintptr(unsafe.Pointer(&data)))
+}
+
+// trayWorker checks at once, then every interval, and runs whatever the menu
+// asks for in between. Runs never overlap, a batch isn't reentrant.
+func (b *batch) trayWorker(t *trayIcon, interval time.Duration, jitter float64) {
+	timer := time.NewTimer(0)
+	for {
+		req := trayCheck
+		select {
+		case <-timer.C:
+		case req = <-t.requests:
+			if !timer.Stop() {
+				<-timer.C
+			}
+		}
+		t.setTip(tr("elvuiUpdater: checking"))
+		if title, text := b.trayRun(req); text != "" {
+			t.balloon(title, text)
+		}
+		wait := jittered(interval, jitter)
+		t.setTip(fmt.Sprintf(tr("elvuiUpdater: next check at %s"), time.Now().Add(wait).Format("15:04")))
+		timer.Reset(wait)
+	}
+}
+
+// trayRun runs b once, as -check unless an update was asked for, and words
+// the outcome for a notification, no text when there is nothing to tell.
+func (b *batch) trayRun(req trayRequest) (string, string) {
+	run := *b
+	run.opts.check = req == trayCheck
+	code := run.run()
+
+	var lines []string
+	for _, e := range run.last {
+		switch e.status() {
+		case "update-available":
+			lines = append(lines, fmt.Sprintf("%s %s -> %s", e.Name, e.localVersion, e.remoteVersion))
+		case "updated":
+			lines = append(lines, fmt.Sprintf(tr("%s updated to %s"), e.Name, e.remoteVersion))
+		case "error":
+			lines = append(lines, fmt.Sprintf(tr("%s failed: %v"), e.Name, e.err))
+		}
+	}
+	title := tr("Updates installed")
+	switch code {
+	case exitUpdateAvailable:
+		title = tr("Updates available, pick Update now to install them")
+	case exitPartialFailure, exitTotalFailure:
+		title = tr("Some addons failed")
+	case exitTimeout:
+		title = tr("The run timed out")
+		lines = append(lines, fmt.Sprintf(tr("%d of %d addons were processed"), len(run.last), len(b.addons)))
+	}
+	return title, strings.Join(lines, "\n")
+}
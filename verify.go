@@ -0,0 +1,77 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// verify compares the installed files with the manifest recorded by the last
+// install and reports missing, extra or modified files, changing nothing.
+func (e *elvui) verify(st *state) error {
+	expected, err := st.loadManifest(e.Name)
+	if err != nil {
+		return err
+	}
+
+	installed, err := e.scanInstalled(expected)
+	if err != nil {
+		return err
+	}
+
+	missing, extra, modified := expected.compare(installed)
+	if len(missing) == 0 && len(extra) == 0 && len(modified) == 0 {
+		e.infof("%s %s: %d files match the last install\n", e.Name, e.localVersion, len(expected))
+		return nil
+	}
+	e.infof("%s %s: %d missing, %d extra, %d modified\n", e.Name, e.localVersion, len(missing), len(extra), len(modified))
+	for _, name := range missing {
+		e.infof("  - %s\n", name)
+	}
+	for _, name := range extra {
+		e.infof("  + %s\n", name)
+	}
+	for _, name := range modified {
+		e.infof("  ~ %s\n", name)
+	}
+	return errors.Errorf("installed files don't match the last install")
+}
+
+// scanInstalled hashes every file below the top-level folders of m as they
+// are on disk.
+func (e *elvui) scanInstalled(m manifest) (manifest, error) {
+	roots := map[string]bool{}
+	for path := range m {
+		roots[strings.SplitN(path, "/", 2)[0]] = true
+	}
+	installed := manifest{}
+	for dir := range roots {
+		root := filepath.Join(e.addon, dir)
+		err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+			if os.IsNotExist(err) && path == root {
+				return filepath.SkipDir
+			} else if err != nil {
+				return err
+			}
+			if info.IsDir() {
+				return nil
+			}
+			rel, err := filepath.Rel(e.addon, path)
+			if err != nil {
+				return err
+			}
+			sum, err := hashFile(path)
+			if err != nil {
+				return err
+			}
+			installed[filepath.ToSlash(rel)] = sum
+			return nil
+		})
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot walk %s", root)
+		}
+	}
+	return installed, nil
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// version is a dotted version such as 13.05 or 1.2.3. Components compare as
+// integers and missing components count as zero, so 1.2 equals 1.2.0. A
+// semver pre-release such as 1.2.3-beta.1 sorts before 1.2.3.
+type version struct {
+	raw   string
+	parts []string
+	pre   []string
+}
+
+// parseVersion parses s with the addon's VersionScheme.
+func (e *elvui) parseVersion(s string) (version, error) {
+	return parseVersionScheme(s, e.VersionScheme)
+}
+
+// parseVersionScheme parses s as a numeric (13.05), semver (1.2.3-beta.1) or
+// date (2024-11-05) version. An empty scheme tries numeric, date and semver
+// in that order.
+func parseVersionScheme(s, scheme string) (version, error) {
+	raw := strings.TrimSpace(s)
+	trimmed := strings.TrimPrefix(strings.TrimPrefix(raw, "v"), "V")
+	if trimmed == "" {
+		return version{}, errors.Errorf("empty version %q", s)
+	}
+
+	scheme, err := normalizeVersionScheme(scheme)
+	if err != nil {
+		return version{}, err
+	}
+	var parse func(string) ([]string, []string, bool)
+	switch scheme {
+	case "":
+		parse = func(t string) ([]string, []string, bool) {
+			for _, p := range []func(string) ([]string, []string, bool){numericVersion, dateVersion, semverVersion} {
+				if parts, pre, ok := p(t); ok {
+					return parts, pre, true
+				}
+			}
+			return nil, nil, false
+		}
+	case "numeric":
+		parse = numericVersion
+	case "semver":
+		parse = semverVersion
+	case "date":
+		parse = dateVersion
+	}
+	parts, pre, ok := parse(trimmed)
+	if !ok {
+		if scheme != "" {
+			return version{}, errors.Errorf("invalid %s version %q", scheme, s)
+		}
+		return version{}, errors.Errorf("invalid version %q", s)
+	}
+	return version{raw: raw, parts: parts, pre: pre}, nil
+}
+
+var versionSchemes = []string{"numeric", "semver", "date"}
+
+// normalizeVersionScheme lowercases s, "" means auto-detect.
+func normalizeVersionScheme(s string) (string, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if s != "" && !containsFold(versionSchemes, s) {
+		return "", errors.Errorf("unknown version scheme %q, expected one of %v", s, versionSchemes)
+	}
+	return s, nil
+}
+
+func numericVersion(s string) ([]string, []string, bool) {
+	parts := strings.Split(s, ".")
+	for _, p := range parts {
+		if _, err := strconv.ParseUint(p, 10, 64); err != nil {
+			return nil, nil, false
+		}
+	}
+	return parts, nil, true
+}
+
+// semverVersion splits off the pre-release, build metadata is ignored.
+func semverVersion(s string) ([]string, []string, bool) {
+	if i := strings.Index(s, "+"); i >= 0 {
+		s = s[:i]
+	}
+	core, pre := s, ""
+	if i := strings.Index(s, "-"); i >= 0 {
+		core, pre = s[:i], s[i+1:]
+	}
+	parts, _, ok := numericVersion(core)
+	if !ok || len(parts) > 3 {
+		return nil, nil, false
+	}
+	if pre == "" {
+		return parts, nil, !strings.Contains(s, "-")
+	}
+	ids := strings.Split(pre, ".")
+	for _, id := range ids {
+		if id == "" {
+			return nil, nil, false
+		}
+	}
+	return parts, ids, true
+}
+
+// dateVersion reads 2024.11.05, 2024-11-05 or 20241105 as year, month and
+// day, rejecting dates that don't exist.
+func dateVersion(s string) ([]string, []string, bool) {
+	parts := strings.FieldsFunc(s, func(r rune) bool { return r == '.' || r == '-' })
+	if len(parts) == 1 && len(s) == 8 {
+		parts = []string{s[:4], s[4:6], s[6:]}
+	}
+	if len(parts) != 3 || len(parts[0]) != 4 {
+		return nil, nil, false
+	}
+	var n [3]int
+	for i, p := range parts {
+		v, err := strconv.Atoi(p)
+		if err != nil {
+			return nil, nil, false
+		}
+		n[i] = v
+	}
+	t := time.Date(n[0], time.Month(n[1]), n[2], 0, 0, 0, 0, time.UTC)
+	if t.Year() != n[0] || int(t.Month()) != n[1] || t.Day() != n[2] {
+		return nil, nil, false
+	}
+	return parts, nil, true
+}
+
+var versionPattern = regexp.MustCompile(`\d+(\.\d+)+`)
+
+// compare returns -1, 0 or 1 when v is older, equal or newer than o.
+func (v version) compare(o version) int {
+	for i := 0; i < len(v.parts) || i < len(o.parts); i++ {
+		a, b := v.component(i), o.component(i)
+		if a < b {
+			return -1
+		} else if a > b {
+			return 1
+		}
+	}
+	return comparePre(v.pre, o.pre)
+}
+
+// comparePre orders semver pre-releases, none being the newest. Numeric
+// identifiers compare as numbers and sort before alphanumeric ones.
+func comparePre(a, b []string) int {
+	switch {
+	case len(a) == 0 && len(b) == 0:
+		return 0
+	case len(a) == 0:
+		return 1
+	case len(b) == 0:
+		return -1
+	}
+	for i := 0; i < len(a) && i < len(b); i++ {
+		x, errX := strconv.ParseUint(a[i], 10, 64)
+		y, errY := strconv.ParseUint(b[i], 10, 64)
+		switch {
+		case errX == nil && errY == nil && x != y:
+			if x < y {
+				return -1
+			}
+			return 1
+		case errX == nil && errY != nil:
+			return -1
+		case errX != nil && errY == nil:
+			return 1
+		case errX != nil && a[i] != b[i]:
+			if a[i] < b[i] {
+				return -1
+			}
+			return 1
+		}
+	}
+	switch {
+	case len(a) < len(b):
+		return -1
+	case len(a) > len(b):
+		return 1
+	}
+	return 0
+}
+
+func (v version) component(i int) uint64 {
+	if i >= len(v.parts) {
+		return 0
+	}
+	n, _ := strconv.ParseUint(v.parts[i], 10, 64)
+	return n
+}
+
+// float keeps the first two components as major.minor for numeric sinks like
+// metrics, e.g. 13.05 stays 13.05 and 1.2.3 becomes 1.2.
+func (v version) float() float64 {
+	if len(v.parts) == 0 {
+		return 0
+	}
+	s := v.parts[0]
+	if len(v.parts) > 1 {
+		s += "." + v.parts[1]
+	}
+	f, _ := strconv.ParseFloat(s, 64)
+	return f
+}
+
+func (v version) String() string {
+	if v.raw == "" {
+		return "none"
+	}
+	return v.raw
+}